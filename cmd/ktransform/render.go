@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mgoltzsche/ktransform/pkg/apis"
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+type renderOptions struct {
+	Files     []string
+	Namespace string
+	Decode    bool
+}
+
+// render reads SecretTransforms and their inputs from files (or stdin)
+// and writes the transformed outputs to stdout
+func render(args []string) error {
+	var opts renderOptions
+	flags := pflag.NewFlagSet("render", pflag.ContinueOnError)
+	flags.StringArrayVarP(&opts.Files, "file", "f", []string{"-"}, "File containing SecretTransforms and input objects ('-' reads stdin)")
+	flags.StringVarP(&opts.Namespace, "namespace", "n", "default", "Namespace of objects that don't specify one")
+	flags.BoolVar(&opts.Decode, "decode", false, "Print decoded Secret data as stringData")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := apis.AddToScheme(scheme); err != nil {
+		return err
+	}
+	var objects []*unstructured.Unstructured
+	for _, file := range opts.Files {
+		o, err := readObjects(file, opts.Namespace)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, o...)
+	}
+	loader := &pipeline.InputLoader{
+		Client:            pipeline.NewObjectReader(scheme, objects),
+		AllowedNamespaces: []string{"*"},
+	}
+	transformGVK := ktransformv1alpha1.SchemeGroupVersion.WithKind("SecretTransform")
+	clusterTransformGVK := ktransformv1alpha1.SchemeGroupVersion.WithKind("ClusterSecretTransform")
+	failed := false
+	for _, o := range objects {
+		if o.GroupVersionKind() != transformGVK && o.GroupVersionKind() != clusterTransformGVK {
+			continue
+		}
+		// a ClusterSecretTransform is rendered as SecretTransform without namespace
+		cr := &ktransformv1alpha1.SecretTransform{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, cr)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", o.GetKind(), o.GetName(), err)
+		}
+		if o.GroupVersionKind() == clusterTransformGVK {
+			cr.Namespace = ""
+			if errs := pipeline.ValidateClusterScopedSpec(&cr.Spec, field.NewPath("spec")); len(errs) > 0 {
+				return fmt.Errorf("%s %s: %w", o.GetKind(), o.GetName(), errs.ToAggregate())
+			}
+		}
+		_, scope, err := loader.Load(cr.Namespace, cr.Spec.Input)
+		if err != nil {
+			return fmt.Errorf("SecretTransform %s: %w", cr.Name, err)
+		}
+		if scope, err = generate(loader.Client, cr, scope); err != nil {
+			return fmt.Errorf("SecretTransform %s: %w", cr.Name, err)
+		}
+		namespaces := &pipeline.OutputNamespaces{
+			Default: cr.Namespace,
+			Allowed: []string{"*"},
+			Client:  loader.Client,
+		}
+		for _, out := range pipeline.Transform(scope, cr.Spec.Output, namespaces, nil) {
+			if out.Err != nil {
+				fmt.Fprintf(os.Stderr, "error: SecretTransform %s: %s\n", cr.Name, out.Err)
+				failed = true
+				continue
+			}
+			if err = out.Apply(); err != nil {
+				return fmt.Errorf("SecretTransform %s: output %d: %w", cr.Name, out.Index, err)
+			}
+			if err = writeObject(os.Stdout, scheme, out.Resource, opts.Decode); err != nil {
+				return err
+			}
+		}
+	}
+	if failed {
+		return errors.New("failed to transform outputs")
+	}
+	return nil
+}
+
+func readObjects(file, defaultNamespace string) (l []*unstructured.Unstructured, err error) {
+	var reader io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+	dec := yaml.NewYAMLOrJSONDecoder(reader, 4096)
+	for {
+		o := &unstructured.Unstructured{}
+		if err = dec.Decode(&o.Object); err != nil {
+			if err == io.EOF {
+				return l, nil
+			}
+			return nil, fmt.Errorf("read %s: %w", file, err)
+		}
+		if len(o.Object) == 0 {
+			continue
+		}
+		if o.GetNamespace() == "" {
+			o.SetNamespace(defaultNamespace)
+		}
+		if err = mergeStringData(o); err != nil {
+			return nil, fmt.Errorf("read %s: %w", file, err)
+		}
+		l = append(l, o)
+	}
+}
+
+// mergeStringData merges a Secret's stringData into its data as the API server does
+func mergeStringData(o *unstructured.Unstructured) error {
+	if o.GetAPIVersion() != "v1" || o.GetKind() != "Secret" {
+		return nil
+	}
+	sec := &corev1.Secret{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, sec)
+	if err != nil {
+		return err
+	}
+	if len(sec.StringData) == 0 {
+		return nil
+	}
+	if sec.Data == nil {
+		sec.Data = map[string][]byte{}
+	}
+	for k, v := range sec.StringData {
+		sec.Data[k] = []byte(v)
+	}
+	sec.StringData = nil
+	o.Object, err = runtime.DefaultUnstructuredConverter.ToUnstructured(sec)
+	return err
+}
+
+func writeObject(w io.Writer, scheme *runtime.Scheme, o pipeline.Resource, decode bool) error {
+	gvk, err := apiutil.GVKForObject(o, scheme)
+	if err != nil {
+		return err
+	}
+	o.GetObjectKind().SetGroupVersionKind(gvk)
+	if sec, ok := o.(*corev1.Secret); ok && decode {
+		sec.StringData = map[string]string{}
+		for k, v := range sec.Data {
+			sec.StringData[k] = string(v)
+		}
+		sec.Data = nil
+	}
+	b, err := sigsyaml.Marshal(o)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "---\n%s", b)
+	return err
+}
+
+// generate exposes the values specified by the SecretTransform's generators.
+// Values that are contained in a provided state Secret are reused, others are generated.
+func generate(c client.Reader, cr *ktransformv1alpha1.SecretTransform, inputs func() map[string]interface{}) (func() map[string]interface{}, error) {
+	if len(cr.Spec.Generate) == 0 {
+		return inputs, nil
+	}
+	state := &corev1.Secret{}
+	key := types.NamespacedName{Name: pipeline.StateSecretName(cr.Name), Namespace: cr.Namespace}
+	if err := c.Get(context.TODO(), key, state); err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if _, err := pipeline.Generate(cr.Spec.Input, cr.Spec.Generate, state); err != nil {
+		return nil, err
+	}
+	return pipeline.GeneratedScope(inputs, cr.Spec.Generate, state)
+}
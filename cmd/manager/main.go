@@ -15,6 +15,7 @@ import (
 
 	"github.com/mgoltzsche/ktransform/pkg/apis"
 	"github.com/mgoltzsche/ktransform/pkg/controller"
+	"github.com/mgoltzsche/ktransform/pkg/webhook"
 	"github.com/mgoltzsche/ktransform/version"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -38,6 +39,7 @@ var (
 	metricsHost               = "0.0.0.0"
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
+	webhookPort               = 9443
 )
 var log = logf.Log.WithName("cmd")
 
@@ -57,6 +59,14 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	var controllerOpts controller.Options
+	pflag.StringSliceVar(&controllerOpts.AllowedInputNamespaces, "allowed-input-namespaces", nil,
+		"Namespaces SecretTransforms may read inputs from in addition to their own namespace ('*' allows any)")
+	pflag.StringSliceVar(&controllerOpts.AllowedOutputNamespaces, "allowed-output-namespaces", nil,
+		"Namespaces SecretTransforms may write outputs to in addition to their own namespace ('*' allows any)")
+	enableWebhooks := pflag.Bool("enable-webhooks", false,
+		"Serve the validating admission webhook (requires a TLS certificate within /tmp/k8s-webhook-server/serving-certs)")
+
 	pflag.Parse()
 
 	// Use a zap logr.Logger implementation. If none of the zap
@@ -92,10 +102,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// ClusterSecretTransforms can only be reconciled when watching all namespaces
+	controllerOpts.ClusterScoped = namespace == ""
+	if namespace != "" {
+		controllerOpts.WatchNamespaces = strings.Split(namespace, ",")
+	}
+
 	// Set default manager options
 	options := manager.Options{
 		Namespace:          namespace,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Port:               webhookPort,
 	}
 
 	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
@@ -123,11 +140,19 @@ func main() {
 	}
 
 	// Setup all Controllers
-	if err := controller.AddToManager(mgr); err != nil {
+	if err := controller.AddToManager(mgr, controllerOpts); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
+	// Setup webhooks
+	if *enableWebhooks {
+		if err := webhook.AddToManager(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Add the Metrics Service
 	addMetrics(ctx, cfg)
 
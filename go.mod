@@ -1,13 +1,20 @@
 module github.com/mgoltzsche/ktransform
 
-go 1.13
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v0.3.1
+	github.com/Masterminds/sprig/v3 v3.1.0
 	github.com/go-logr/logr v0.1.0
-	github.com/itchyny/gojq v0.10.3
+	github.com/google/uuid v1.1.1
+	github.com/itchyny/gojq v0.12.7
+	github.com/joho/godotenv v1.3.0
+	github.com/magiconair/properties v1.8.0
 	github.com/operator-framework/operator-sdk v0.18.2
+	github.com/prometheus/client_golang v1.5.1
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.5.1
+	golang.org/x/crypto v0.0.0-20200414173820-0848c9571904
 	k8s.io/api v0.18.2
 	k8s.io/apimachinery v0.18.2
 	k8s.io/client-go v12.0.0+incompatible
@@ -0,0 +1,43 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterSecretTransform is the Schema for the clustersecrettransforms API.
+// It is the cluster-scoped variant of SecretTransform:
+// all inputs and outputs must specify a namespace.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=clustersecrettransforms,scope=Cluster
+type ClusterSecretTransform struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SecretTransformSpec   `json:"spec,omitempty"`
+	Status SecretTransformStatus `json:"status,omitempty"`
+}
+
+// GetSpec returns the transformation spec
+func (t *ClusterSecretTransform) GetSpec() *SecretTransformSpec {
+	return &t.Spec
+}
+
+// GetStatus returns the transformation status
+func (t *ClusterSecretTransform) GetStatus() *SecretTransformStatus {
+	return &t.Status
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterSecretTransformList contains a list of ClusterSecretTransform
+type ClusterSecretTransformList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterSecretTransform `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterSecretTransform{}, &ClusterSecretTransformList{})
+}
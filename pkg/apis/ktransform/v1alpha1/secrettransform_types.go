@@ -12,6 +12,8 @@ const (
 	ReasonInvalidSpec     = status.ConditionReason("InvalidSpec")
 	ReasonFailedTransform = status.ConditionReason("FailedTransform")
 	ReasonFailedWrite     = status.ConditionReason("FailedWrite")
+	ReasonFailedRollout   = status.ConditionReason("FailedRollout")
+	ReasonForbidden       = status.ConditionReason("Forbidden")
 	ReasonFailed          = status.ConditionReason("Failed")
 )
 
@@ -19,26 +21,224 @@ const (
 type SecretTransformSpec struct {
 	Input  map[string]InputRef `json:"input,omitempty"`
 	Output []Output            `json:"output"`
+	// FailurePolicy specifies how a failing output affects the other outputs.
+	// Abort (default) does not write any further output, Continue writes the other outputs.
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
+	// Generate specifies values that are generated once and exposed to the transformation like inputs.
+	// Generated values are stored in the Secret <name>-ktransform-state.
+	Generate map[string]Generator `json:"generate,omitempty"`
 }
 
+// +kubebuilder:validation:Enum=Abort;Continue
+type FailurePolicy string
+
+const (
+	FailurePolicyAbort    FailurePolicy = "Abort"
+	FailurePolicyContinue FailurePolicy = "Continue"
+)
+
 type InputRef struct {
 	Secret    *string `json:"secret,omitempty"`
 	ConfigMap *string `json:"configMap,omitempty"`
+	// SecretSelector selects Secrets by label.
+	// The matching Secrets are exposed as a map keyed by Secret name.
+	SecretSelector *metav1.LabelSelector `json:"secretSelector,omitempty"`
+	// ConfigMapSelector selects ConfigMaps by label.
+	// The matching ConfigMaps are exposed as a map keyed by ConfigMap name.
+	ConfigMapSelector *metav1.LabelSelector `json:"configMapSelector,omitempty"`
+	// APIVersion, Kind and Name refer to an arbitrary object that is exposed as a whole.
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// Formats maps data keys of Secret and ConfigMap inputs to the format their values are parsed as.
+	// By default the format is derived from the key's extension (.properties, .env, .ini, .toml, .xml).
+	// A parsed value is exposed using the format's name as key (e.g. .mykey.properties).
+	Formats map[string]InputFormat `json:"formats,omitempty"`
+	// Namespace the input is read from. Defaults to the SecretTransform's namespace.
+	// Other namespaces must be allowed by the operator.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=yaml;properties;env;ini;toml;xml
+type InputFormat string
+
+// Generator specifies how a value is generated.
+// A value is regenerated only when its rotation or the kind of the generator
+// (randomString, privateKey, uuid or ca) changes.
+// Other changes to the generator are applied with the next rotation.
+type Generator struct {
+	RandomString *RandomStringGenerator `json:"randomString,omitempty"`
+	PrivateKey   *PrivateKeyGenerator   `json:"privateKey,omitempty"`
+	UUID         *UUIDGenerator         `json:"uuid,omitempty"`
+	// CA generates a self-signed CA that can issue certificates into Secret outputs
+	CA *CAGenerator `json:"ca,omitempty"`
+	// Rotation can be changed to any other value in order to regenerate the value
+	Rotation string `json:"rotation,omitempty"`
+}
+
+// RandomStringGenerator generates a random string
+type RandomStringGenerator struct {
+	// Length of the string. Defaults to 32.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4096
+	Length int `json:"length,omitempty"`
+	// Charset the string is composed of. Defaults to alphanumeric characters.
+	Charset string `json:"charset,omitempty"`
+}
+
+// PrivateKeyGenerator generates a PEM encoded (PKCS#8) private key
+type PrivateKeyGenerator struct {
+	Type KeyType `json:"type"`
+	// Bits is the RSA key size (default 2048) or the ECDSA curve size (default 256)
+	Bits int `json:"bits,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=RSA;ECDSA;Ed25519
+type KeyType string
+
+const (
+	KeyTypeRSA     KeyType = "RSA"
+	KeyTypeECDSA   KeyType = "ECDSA"
+	KeyTypeEd25519 KeyType = "Ed25519"
+)
+
+// UUIDGenerator generates a random UUID
+type UUIDGenerator struct{}
+
+// CAGenerator generates a self-signed CA certificate and its private key
+type CAGenerator struct {
+	// CommonName of the CA. Defaults to the generator's name.
+	CommonName string `json:"commonName,omitempty"`
+	// Duration the CA certificate is valid for. Defaults to 87600h (10 years).
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// PrivateKey specifies the CA key. Defaults to an ECDSA P-256 key.
+	PrivateKey *PrivateKeyGenerator `json:"privateKey,omitempty"`
 }
 
 type Output struct {
 	Secret         *SecretOutput     `json:"secret,omitempty"`
 	ConfigMap      *ConfigMapOutput  `json:"configMap,omitempty"`
 	Transformation map[string]string `json:"transformation,omitempty"`
+	// Items is a query that returns a list of objects with name, data and optionally labels and annotations.
+	// One Secret or ConfigMap is written per item using the secret or configMap spec without name.
+	// It cannot be combined with transformation or dataTransformation.
+	Items string `json:"items,omitempty"`
+	// DataTransformation is a query that returns an object whose entries are written as keys.
+	// Keys that are also specified within transformation are overwritten by the latter.
+	DataTransformation string `json:"dataTransformation,omitempty"`
+	// Formats maps transformation keys to the format their results are serialized as.
+	// By default strings are written as is and other values as JSON.
+	Formats map[string]OutputFormat `json:"formats,omitempty"`
+	// Object is a query that returns a whole object (apiVersion, kind, metadata, spec, ...) to be written.
+	// It cannot be combined with secret, configMap, transformation or dataTransformation.
+	Object string `json:"object,omitempty"`
+	// Namespace the output is written to. Defaults to the SecretTransform's namespace.
+	// Writing to another namespace requires the operator to allow it.
+	Namespace string `json:"namespace,omitempty"`
+	// NamespaceSelector makes the output be written to every (allowed) namespace matching the selector.
+	// It cannot be combined with namespace.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Engine evaluates the output's queries: jq (default) or template (Go text/template with sprig functions)
+	Engine Engine `json:"engine,omitempty"`
+	// PrunePolicy specifies what happens to the written object when the output is removed from the spec or renamed.
+	// Delete (default) deletes the object, Orphan removes the SecretTransform from the object's ownerReferences.
+	PrunePolicy PrunePolicy `json:"prunePolicy,omitempty"`
+	// WriteMode specifies how the output is written.
+	// Replace (default) writes the whole object and makes the SecretTransform its controller.
+	// Merge server-side applies only the transformed keys, labels and annotations to a (possibly
+	// externally owned) Secret or ConfigMap without taking ownership and without removing foreign keys.
+	WriteMode WriteMode `json:"writeMode,omitempty"`
+	// RolloutTargets lists workloads within the output's namespace that are restarted when the output is updated.
+	// Their pod template is annotated with the hash of the output's data.
+	RolloutTargets []RolloutTarget `json:"rolloutTargets,omitempty"`
 }
 
-type SecretOutput struct {
+// RolloutTarget refers to a workload whose pods are restarted when an output is updated
+type RolloutTarget struct {
+	Kind RolloutTargetKind `json:"kind"`
 	Name string            `json:"name"`
-	Type corev1.SecretType `json:"type,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet
+type RolloutTargetKind string
+
+const (
+	RolloutTargetDeployment  RolloutTargetKind = "Deployment"
+	RolloutTargetStatefulSet RolloutTargetKind = "StatefulSet"
+	RolloutTargetDaemonSet   RolloutTargetKind = "DaemonSet"
+)
+
+// +kubebuilder:validation:Enum=json;prettyjson;yaml;toml;properties;env;ini
+type OutputFormat string
+
+// +kubebuilder:validation:Enum=jq;template
+type Engine string
+
+const (
+	EngineJQ       Engine = "jq"
+	EngineTemplate Engine = "template"
+)
+
+// +kubebuilder:validation:Enum=Delete;Orphan
+type PrunePolicy string
+
+const (
+	PrunePolicyDelete PrunePolicy = "Delete"
+	PrunePolicyOrphan PrunePolicy = "Orphan"
+)
+
+// +kubebuilder:validation:Enum=Replace;Merge
+type WriteMode string
+
+const (
+	WriteModeReplace WriteMode = "Replace"
+	WriteModeMerge   WriteMode = "Merge"
+)
+
+type SecretOutput struct {
+	// Name of the Secret. Must be empty when the output specifies items.
+	Name           string            `json:"name,omitempty"`
+	Type           corev1.SecretType `json:"type,omitempty"`
+	OutputMetadata `json:",inline"`
+	// Certificate issues a certificate into the Secret's tls.crt, tls.key and ca.crt keys.
+	// The Secret's type defaults to kubernetes.io/tls.
+	Certificate *CertificateOutput `json:"certificate,omitempty"`
+}
+
+// CertificateOutput specifies a certificate issued by a generated CA.
+// The certificate is reissued when it is due for renewal or its spec changed.
+type CertificateOutput struct {
+	// Issuer is the name of the ca generator that issues the certificate
+	Issuer string `json:"issuer"`
+	// CommonName is a query that returns the certificate's common name
+	CommonName string `json:"commonName,omitempty"`
+	// DNSNames is a query that returns a DNS name or a list of DNS names
+	DNSNames string `json:"dnsNames,omitempty"`
+	// IPAddresses is a query that returns an IP address or a list of IP addresses
+	IPAddresses string `json:"ipAddresses,omitempty"`
+	// Duration the certificate is valid for. Defaults to 2160h (90 days).
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// RenewBefore is the time before expiry the certificate is renewed at. Defaults to a third of its duration.
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+	// PrivateKey specifies the certificate's key. Defaults to an ECDSA P-256 key.
+	PrivateKey *PrivateKeyGenerator `json:"privateKey,omitempty"`
 }
 
 type ConfigMapOutput struct {
-	Name string `json:"name"`
+	// Name of the ConfigMap. Must be empty when the output specifies items.
+	Name           string `json:"name,omitempty"`
+	OutputMetadata `json:",inline"`
+}
+
+// OutputMetadata specifies labels and annotations of an output.
+// Labels and annotations that are not specified here are preserved.
+type OutputMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// LabelTransformation maps label keys to queries that compute their values
+	LabelTransformation map[string]string `json:"labelTransformation,omitempty"`
+	// AnnotationTransformation maps annotation keys to queries that compute their values
+	AnnotationTransformation map[string]string `json:"annotationTransformation,omitempty"`
 }
 
 // SecretTransformStatus defines the observed state of SecretTransform
@@ -47,11 +247,33 @@ type SecretTransformStatus struct {
 	Conditions         status.Conditions  `json:"conditions,omitempty"`
 	ManagedReferences  []ManagedReference `json:"managedReferences,omitempty"`
 	OutputHash         string             `json:"outputHash,omitempty"`
+	// Outputs lists the objects that have been written
+	Outputs []OutputStatus `json:"outputs,omitempty"`
+}
+
+type OutputStatus struct {
+	// Index of the output within the spec
+	Index      int    `json:"index"`
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// Namespace of the written object if it differs from the SecretTransform's namespace
+	Namespace   string      `json:"namespace,omitempty"`
+	PrunePolicy PrunePolicy `json:"prunePolicy,omitempty"`
+	WriteMode   WriteMode   `json:"writeMode,omitempty"`
+	// Hash of the written data
+	Hash          string       `json:"hash,omitempty"`
+	LastWriteTime *metav1.Time `json:"lastWriteTime,omitempty"`
+	// Reason and Message explain why the output could not be written
+	Reason  status.ConditionReason `json:"reason,omitempty"`
+	Message string                 `json:"message,omitempty"`
 }
 
 type ManagedReference struct {
-	Kind string `json:"kind"`
-	Name string `json:"name"`
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -67,6 +289,16 @@ type SecretTransform struct {
 	Status SecretTransformStatus `json:"status,omitempty"`
 }
 
+// GetSpec returns the transformation spec
+func (t *SecretTransform) GetSpec() *SecretTransformSpec {
+	return &t.Spec
+}
+
+// GetStatus returns the transformation status
+func (t *SecretTransform) GetStatus() *SecretTransformStatus {
+	return &t.Status
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // SecretTransformList contains a list of SecretTransform
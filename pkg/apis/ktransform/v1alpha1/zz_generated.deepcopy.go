@@ -6,12 +6,132 @@ package v1alpha1
 
 import (
 	status "github.com/operator-framework/operator-sdk/pkg/status"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CAGenerator) DeepCopyInto(out *CAGenerator) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = new(PrivateKeyGenerator)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CAGenerator.
+func (in *CAGenerator) DeepCopy() *CAGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(CAGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateOutput) DeepCopyInto(out *CertificateOutput) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RenewBefore != nil {
+		in, out := &in.RenewBefore, &out.RenewBefore
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = new(PrivateKeyGenerator)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateOutput.
+func (in *CertificateOutput) DeepCopy() *CertificateOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterSecretTransform) DeepCopyInto(out *ClusterSecretTransform) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSecretTransform.
+func (in *ClusterSecretTransform) DeepCopy() *ClusterSecretTransform {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterSecretTransform)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterSecretTransform) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterSecretTransformList) DeepCopyInto(out *ClusterSecretTransformList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterSecretTransform, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSecretTransformList.
+func (in *ClusterSecretTransformList) DeepCopy() *ClusterSecretTransformList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterSecretTransformList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterSecretTransformList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigMapOutput) DeepCopyInto(out *ConfigMapOutput) {
 	*out = *in
+	in.OutputMetadata.DeepCopyInto(&out.OutputMetadata)
 	return
 }
 
@@ -25,6 +145,42 @@ func (in *ConfigMapOutput) DeepCopy() *ConfigMapOutput {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Generator) DeepCopyInto(out *Generator) {
+	*out = *in
+	if in.RandomString != nil {
+		in, out := &in.RandomString, &out.RandomString
+		*out = new(RandomStringGenerator)
+		**out = **in
+	}
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = new(PrivateKeyGenerator)
+		**out = **in
+	}
+	if in.UUID != nil {
+		in, out := &in.UUID, &out.UUID
+		*out = new(UUIDGenerator)
+		**out = **in
+	}
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(CAGenerator)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Generator.
+func (in *Generator) DeepCopy() *Generator {
+	if in == nil {
+		return nil
+	}
+	out := new(Generator)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InputRef) DeepCopyInto(out *InputRef) {
 	*out = *in
@@ -38,6 +194,23 @@ func (in *InputRef) DeepCopyInto(out *InputRef) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.SecretSelector != nil {
+		in, out := &in.SecretSelector, &out.SecretSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapSelector != nil {
+		in, out := &in.ConfigMapSelector, &out.ConfigMapSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Formats != nil {
+		in, out := &in.Formats, &out.Formats
+		*out = make(map[string]InputFormat, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -73,12 +246,12 @@ func (in *Output) DeepCopyInto(out *Output) {
 	if in.Secret != nil {
 		in, out := &in.Secret, &out.Secret
 		*out = new(SecretOutput)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.ConfigMap != nil {
 		in, out := &in.ConfigMap, &out.ConfigMap
 		*out = new(ConfigMapOutput)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Transformation != nil {
 		in, out := &in.Transformation, &out.Transformation
@@ -87,6 +260,23 @@ func (in *Output) DeepCopyInto(out *Output) {
 			(*out)[key] = val
 		}
 	}
+	if in.Formats != nil {
+		in, out := &in.Formats, &out.Formats
+		*out = make(map[string]OutputFormat, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RolloutTargets != nil {
+		in, out := &in.RolloutTargets, &out.RolloutTargets
+		*out = make([]RolloutTarget, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -100,9 +290,127 @@ func (in *Output) DeepCopy() *Output {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputMetadata) DeepCopyInto(out *OutputMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LabelTransformation != nil {
+		in, out := &in.LabelTransformation, &out.LabelTransformation
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AnnotationTransformation != nil {
+		in, out := &in.AnnotationTransformation, &out.AnnotationTransformation
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputMetadata.
+func (in *OutputMetadata) DeepCopy() *OutputMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputStatus) DeepCopyInto(out *OutputStatus) {
+	*out = *in
+	if in.LastWriteTime != nil {
+		in, out := &in.LastWriteTime, &out.LastWriteTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputStatus.
+func (in *OutputStatus) DeepCopy() *OutputStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateKeyGenerator) DeepCopyInto(out *PrivateKeyGenerator) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateKeyGenerator.
+func (in *PrivateKeyGenerator) DeepCopy() *PrivateKeyGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateKeyGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RandomStringGenerator) DeepCopyInto(out *RandomStringGenerator) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RandomStringGenerator.
+func (in *RandomStringGenerator) DeepCopy() *RandomStringGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(RandomStringGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutTarget) DeepCopyInto(out *RolloutTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutTarget.
+func (in *RolloutTarget) DeepCopy() *RolloutTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretOutput) DeepCopyInto(out *SecretOutput) {
 	*out = *in
+	in.OutputMetadata.DeepCopyInto(&out.OutputMetadata)
+	if in.Certificate != nil {
+		in, out := &in.Certificate, &out.Certificate
+		*out = new(CertificateOutput)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -194,6 +502,13 @@ func (in *SecretTransformSpec) DeepCopyInto(out *SecretTransformSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Generate != nil {
+		in, out := &in.Generate, &out.Generate
+		*out = make(map[string]Generator, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	return
 }
 
@@ -222,6 +537,13 @@ func (in *SecretTransformStatus) DeepCopyInto(out *SecretTransformStatus) {
 		*out = make([]ManagedReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]OutputStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -234,3 +556,19 @@ func (in *SecretTransformStatus) DeepCopy() *SecretTransformStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UUIDGenerator) DeepCopyInto(out *UUIDGenerator) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UUIDGenerator.
+func (in *UUIDGenerator) DeepCopy() *UUIDGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(UUIDGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
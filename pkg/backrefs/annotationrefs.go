@@ -1,10 +1,15 @@
 package backrefs
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 type ObjectFactory func() Object
@@ -17,13 +22,51 @@ func AnnotationReferences(ownerApiGroup string) BackReferenceStrategy {
 	return &annotationRefs{ownerApiGroup}
 }
 
+// EnqueueRequestForAnnotationReference returns an event handler that enqueues
+// a request for each owner of the given kind that is referred to by an
+// annotation (as written by AnnotationReferences) on the changed object.
+func EnqueueRequestForAnnotationReference(ownerKind, ownerApiGroup string) handler.EventHandler {
+	prefix := annotationPrefix(ownerKind, ownerApiGroup)
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) (r []reconcile.Request) {
+			for k, v := range o.Meta.GetAnnotations() {
+				if !strings.HasPrefix(k, prefix) {
+					continue
+				}
+				l := strings.SplitN(v, "/", 2)
+				if len(l) == 2 && l[0] != "" && l[1] != "" {
+					r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: l[0], Name: l[1]}})
+				}
+			}
+			return
+		}),
+	}
+}
+
+// EnqueueRequestForClusterAnnotationReference returns an event handler that enqueues
+// a request for each cluster-scoped owner of the given kind that is referred to by an
+// annotation (as written by AnnotationReferences) on the changed object.
+func EnqueueRequestForClusterAnnotationReference(ownerKind, ownerApiGroup string) handler.EventHandler {
+	prefix := annotationPrefix(ownerKind, ownerApiGroup)
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) (r []reconcile.Request) {
+			for k, v := range o.Meta.GetAnnotations() {
+				if strings.HasPrefix(k, prefix) && v != "" && !strings.Contains(v, "/") {
+					r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Name: v}})
+				}
+			}
+			return
+		}),
+	}
+}
+
 func (s *annotationRefs) DelReference(from metav1.Object, to Object) bool {
 	m := from.GetAnnotations()
 	if m == nil {
 		return false
 	}
-	a := s.annotation(to)
-	if m[a] == "true" {
+	a, v := s.annotation(to)
+	if m[a] == v {
 		delete(m, a)
 		from.SetAnnotations(m)
 		return true
@@ -36,19 +79,30 @@ func (s *annotationRefs) AddReference(from metav1.Object, to Object) bool {
 	if m == nil {
 		m = map[string]string{}
 	}
-	a := s.annotation(to)
-	if m[a] == "true" {
+	a, v := s.annotation(to)
+	if m[a] == v {
 		return false
 	}
-	m[a] = "true"
+	m[a] = v
 	from.SetAnnotations(m)
 	return true
 }
 
-func (s *annotationRefs) annotation(o Object) string {
+// annotation returns the key and value of the annotation that refers to the owner.
+// The value is <namespace>/<name> or <name> for a cluster-scoped owner.
+// Since the name part of the key is limited to 63 characters
+// it is a hash of the value.
+func (s *annotationRefs) annotation(o Object) (key, value string) {
 	kind := o.GetObjectKind().GroupVersionKind().Kind
 	checkKind(kind)
-	ns := o.GetNamespace()
-	name := o.GetName()
-	return fmt.Sprintf("%s.%s/%s/%s", strings.ToLower(kind), s.ownerApiGroup, ns, name)
+	value = o.GetName()
+	if o.GetNamespace() != "" {
+		value = o.GetNamespace() + "/" + value
+	}
+	h := sha256.Sum256([]byte(value))
+	return annotationPrefix(kind, s.ownerApiGroup) + hex.EncodeToString(h[:16]), value
+}
+
+func annotationPrefix(kind, apiGroup string) string {
+	return fmt.Sprintf("%s.%s/", strings.ToLower(kind), apiGroup)
 }
@@ -0,0 +1,89 @@
+package backrefs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestEnqueueRequestForAnnotationReference(t *testing.T) {
+	owner := &corev1.ConfigMap{}
+	owner.Kind = "ConfigMap"
+	owner.Namespace = "ns0"
+	owner.Name = "my.conf"
+	ref := &corev1.Secret{}
+	ref.Namespace = "ns1"
+	ref.Name = "secret"
+	ref.Annotations = map[string]string{
+		"unrelated.example.org/other":        "ns0/other",
+		"configmap.other.group/other":        "ns0/other",
+		"configmap." + testAnnotation + "/x": "invalid",
+	}
+	require.True(t, AnnotationReferences(testAnnotation).AddReference(ref, owner), "AddReference")
+
+	testee := EnqueueRequestForAnnotationReference("ConfigMap", testAnnotation)
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+	testee.Update(event.UpdateEvent{MetaOld: ref, ObjectOld: ref, MetaNew: ref, ObjectNew: ref}, q)
+
+	require.Equal(t, 1, q.Len(), "queue length")
+	item, _ := q.Get()
+	expected := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns0", Name: "my.conf"}}
+	require.Equal(t, expected, item, "enqueued request")
+}
+
+func TestEnqueueRequestForClusterAnnotationReference(t *testing.T) {
+	owner := &corev1.Namespace{}
+	owner.Kind = "Namespace"
+	owner.Name = "my.ns"
+	ref := &corev1.Secret{}
+	ref.Namespace = "ns1"
+	ref.Name = "secret"
+	require.True(t, AnnotationReferences(testAnnotation).AddReference(ref, owner), "AddReference")
+	require.Equal(t, map[string]string{"namespace." + testAnnotation + "/383f1e4d88c1736f4597a615a27450c0": "my.ns"}, ref.Annotations, "annotations")
+	testee := EnqueueRequestForClusterAnnotationReference("Namespace", testAnnotation)
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+	testee.Update(event.UpdateEvent{MetaOld: ref, ObjectOld: ref, MetaNew: ref, ObjectNew: ref}, q)
+	require.Equal(t, 1, q.Len(), "queue length")
+	item, _ := q.Get()
+	expected := reconcile.Request{NamespacedName: types.NamespacedName{Name: "my.ns"}}
+	require.Equal(t, expected, item, "enqueued request")
+}
+
+func TestAnnotationReferenceLongName(t *testing.T) {
+	owner := &corev1.ConfigMap{}
+	owner.Kind = "ConfigMap"
+	owner.Namespace = strings.Repeat("n", 63)
+	owner.Name = strings.Repeat("a.", 126) + "b"
+	ref := &corev1.Secret{}
+	ref.Namespace = "ns1"
+	ref.Name = "secret"
+	testee := AnnotationReferences(testAnnotation)
+	require.True(t, testee.AddReference(ref, owner), "AddReference")
+	require.Equal(t, 1, len(ref.Annotations), "annotations")
+	for k, v := range ref.Annotations {
+		require.Empty(t, validation.IsQualifiedName(k), "annotation key %q", k)
+		require.Equal(t, owner.Namespace+"/"+owner.Name, v, "annotation value")
+	}
+
+	handler := EnqueueRequestForAnnotationReference("ConfigMap", testAnnotation)
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+	handler.Update(event.UpdateEvent{MetaOld: ref, ObjectOld: ref, MetaNew: ref, ObjectNew: ref}, q)
+	require.Equal(t, 1, q.Len(), "queue length")
+	item, _ := q.Get()
+	expected := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: owner.Namespace, Name: owner.Name}}
+	require.Equal(t, expected, item, "enqueued request")
+
+	require.False(t, testee.AddReference(ref, owner), "AddReference again")
+	require.True(t, testee.DelReference(ref, owner), "DelReference")
+	require.Empty(t, ref.Annotations, "annotations after DelReference")
+}
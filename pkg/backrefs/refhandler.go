@@ -8,6 +8,7 @@ import (
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -71,7 +72,7 @@ func (h *BackReferencesHandler) UpdateReferences(ctx context.Context, logger log
 func (h *BackReferencesHandler) addNewRefs(ctx context.Context, logger logr.InfoLogger, owner Object, newRefs []Object) error {
 	for _, ref := range newRefs {
 		if h.backRefs.AddReference(ref, owner) {
-			kind := reflect.TypeOf(ref).Elem().Name()
+			kind := kindOf(ref)
 			logger.Info("Adding back reference to "+kind, kind+".Name", ref.GetName(), kind+".Namespace", ref.GetNamespace())
 			if err := h.client.Update(ctx, ref); err != nil {
 				return err
@@ -93,7 +94,7 @@ func (h *BackReferencesHandler) deleteOldRefs(ctx context.Context, logger logr.I
 			}
 		}
 		if h.backRefs.DelReference(ref, owner) {
-			kind := reflect.TypeOf(ref).Elem().Name()
+			kind := kindOf(ref)
 			logger.Info("Removing back reference from "+kind, kind+".Name", ref.GetName(), kind+".Namespace", ref.GetNamespace())
 			if err = h.client.Update(ctx, ref); err != nil && !errors.IsNotFound(err) {
 				return err
@@ -128,5 +129,16 @@ func refKeys(refs []Object) map[string]struct{} {
 }
 
 func refKey(ref Object) string {
+	if u, ok := ref.(*unstructured.Unstructured); ok {
+		// unstructured objects must be distinguished by their kind
+		return fmt.Sprintf("%s:%s/%s", u.GroupVersionKind().GroupKind(), ref.GetNamespace(), ref.GetName())
+	}
 	return fmt.Sprintf("%T:%s/%s", ref, ref.GetNamespace(), ref.GetName())
 }
+
+func kindOf(o Object) string {
+	if u, ok := o.(*unstructured.Unstructured); ok {
+		return u.GetKind()
+	}
+	return reflect.TypeOf(o).Elem().Name()
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -185,10 +186,12 @@ func ownerRefCount(s, o Object) (c int) {
 func secretsByAnnotationRef(secrets []Object, o Object) (r []string) {
 	kind := o.GetObjectKind().GroupVersionKind().Kind
 	checkKind(kind)
-	a := fmt.Sprintf("%s.%s/%s/%s", strings.ToLower(kind), testAnnotation, o.GetNamespace(), o.GetName())
+	prefix := fmt.Sprintf("%s.%s/", strings.ToLower(kind), testAnnotation)
 	for _, s := range secrets {
-		if s.GetAnnotations()[a] == "true" {
-			r = append(r, key(s))
+		for k, v := range s.GetAnnotations() {
+			if strings.HasPrefix(k, prefix) && v == o.GetNamespace()+"/"+o.GetName() {
+				r = append(r, key(s))
+			}
 		}
 	}
 	sort.Strings(r)
@@ -200,3 +203,67 @@ func secretsByAnnotationOrOwnerRef(secrets []Object, o Object) []string {
 	sort.Strings(r)
 	return r
 }
+
+func TestBackReferenceHandlerUnstructured(t *testing.T) {
+	logger := logf.Log
+	ctx := context.TODO()
+	client := fake.NewFakeClient()
+	ownerObj := &corev1.ConfigMap{}
+	ownerObj.Namespace = "ns0"
+	ownerObj.Name = "myconf"
+	sec := &corev1.Secret{}
+	sec.Namespace = "ns0"
+	sec.Name = "secret0"
+	for _, o := range []Object{ownerObj, sec} {
+		err := client.Create(ctx, o)
+		require.NoError(t, err, "create test resource")
+	}
+	loadObjects(t, client, []Object{ownerObj})
+	owner := &sliceOwner{obj: ownerObj}
+	newRef := func() *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("Secret")
+		u.SetNamespace(sec.Namespace)
+		u.SetName(sec.Name)
+		return u
+	}
+	ref := newRef()
+	loadObjects(t, client, []Object{ref})
+	testee := NewBackReferencesHandler(client, OwnerReferences())
+
+	err := testee.UpdateReferences(ctx, logger, owner, []Object{ref})
+	require.NoError(t, err, "UpdateReferences")
+	loadObjects(t, client, []Object{sec})
+	require.Equal(t, []string{key(sec)}, secretsByOwnerRef([]Object{sec}, ownerObj), "back references")
+	version := sec.ResourceVersion
+
+	ref = newRef()
+	loadObjects(t, client, []Object{ref})
+	err = testee.UpdateReferences(ctx, logger, owner, []Object{ref})
+	require.NoError(t, err, "UpdateReferences without changes")
+	loadObjects(t, client, []Object{sec})
+	require.Equal(t, version, sec.ResourceVersion, "resource version changed after update without changes")
+
+	err = testee.UpdateReferences(ctx, logger, owner, nil)
+	require.NoError(t, err, "UpdateReferences to remove refs")
+	loadObjects(t, client, []Object{sec})
+	require.Empty(t, sec.OwnerReferences, "back references after removal")
+}
+
+type sliceOwner struct {
+	obj  Object
+	refs []Object
+}
+
+func (o *sliceOwner) GetStatusReferences() []Object {
+	return o.refs
+}
+
+func (o *sliceOwner) SetStatusReferences(refs []Object) {
+	o.refs = refs
+}
+
+func (o *sliceOwner) GetObject() Object {
+	return o.obj
+}
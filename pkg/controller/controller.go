@@ -1,16 +1,20 @@
 package controller
 
 import (
+	"github.com/mgoltzsche/ktransform/pkg/controller/secrettransform"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
+// Options configures all Controllers
+type Options = secrettransform.Options
+
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
-var AddToManagerFuncs []func(manager.Manager) error
+var AddToManagerFuncs []func(manager.Manager, Options) error
 
 // AddToManager adds all Controllers to the Manager
-func AddToManager(m manager.Manager) error {
+func AddToManager(m manager.Manager, opts Options) error {
 	for _, f := range AddToManagerFuncs {
-		if err := f(m); err != nil {
+		if err := f(m, opts); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,68 @@
+package secrettransform
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// dynamicWatches registers watches for kinds that are only known at runtime
+type dynamicWatches struct {
+	controller controller.Controller
+	apiReader  client.Reader
+	handlers   []handler.EventHandler
+	// namespaces the informers watch (all if empty)
+	namespaces []string
+	watched    map[schema.GroupVersionKind]struct{}
+	mutex      sync.Mutex
+}
+
+func newDynamicWatches(c controller.Controller, apiReader client.Reader, handlers []handler.EventHandler, namespaces []string) *dynamicWatches {
+	return &dynamicWatches{
+		controller: c,
+		apiReader:  apiReader,
+		handlers:   handlers,
+		namespaces: namespaces,
+		watched:    map[schema.GroupVersionKind]struct{}{},
+	}
+}
+
+// Watch makes the controller watch the given kind unless it is watched already.
+// Since an informer never syncs without list permission the permission is
+// checked first within every namespace the informer watches.
+func (w *dynamicWatches) Watch(gvk schema.GroupVersionKind) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if _, ok := w.watched[gvk]; ok {
+		return nil
+	}
+	namespaces := w.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for _, ns := range namespaces {
+		l := &unstructured.UnstructuredList{}
+		l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := w.apiReader.List(context.TODO(), l, client.InNamespace(ns), client.Limit(1))
+		if err != nil {
+			return fmt.Errorf("watch %s: %w", gvk.Kind, err)
+		}
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	for _, h := range w.handlers {
+		if err := w.controller.Watch(&source.Kind{Type: obj}, h); err != nil {
+			return err
+		}
+	}
+	w.watched[gvk] = struct{}{}
+	return nil
+}
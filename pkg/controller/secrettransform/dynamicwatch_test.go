@@ -0,0 +1,76 @@
+package secrettransform
+
+import (
+	"context"
+	goerrors "errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+type listPermissionReader struct {
+	client.Reader
+	allowed map[string]bool
+	listed  []string
+}
+
+func (r *listPermissionReader) List(_ context.Context, _ runtime.Object, opts ...client.ListOption) error {
+	o := &client.ListOptions{}
+	o.ApplyOptions(opts)
+	r.listed = append(r.listed, o.Namespace)
+	if !r.allowed[o.Namespace] {
+		return errors.NewForbidden(schema.GroupResource{Resource: "services"}, "", nil)
+	}
+	return nil
+}
+
+type watchRecorder struct {
+	controller.Controller
+	watches int
+}
+
+func (c *watchRecorder) Watch(source.Source, handler.EventHandler, ...predicate.Predicate) error {
+	c.watches++
+	return nil
+}
+
+func TestDynamicWatches(t *testing.T) {
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+	handlers := []handler.EventHandler{&handler.EnqueueRequestForObject{}}
+	for _, c := range []struct {
+		name       string
+		namespaces []string
+		allowed    map[string]bool
+		listed     []string
+		watches    int
+	}{
+		{"all namespaces", nil, map[string]bool{"": true}, []string{""}, 1},
+		{"all namespaces denied", nil, map[string]bool{"a": true}, []string{""}, 0},
+		{"multiple namespaces", []string{"a", "b"}, map[string]bool{"a": true, "b": true}, []string{"a", "b"}, 1},
+		{"second namespace denied", []string{"a", "b"}, map[string]bool{"a": true}, []string{"a", "b"}, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			reader := &listPermissionReader{allowed: c.allowed}
+			ctrl := &watchRecorder{}
+			w := newDynamicWatches(ctrl, reader, handlers, c.namespaces)
+			err := w.Watch(gvk)
+			if c.watches == 0 {
+				require.Error(t, err)
+				require.True(t, errors.IsForbidden(goerrors.Unwrap(err)), "forbidden error expected")
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, w.Watch(gvk), "watch again")
+			}
+			require.Equal(t, c.listed, reader.listed, "listed namespaces")
+			require.Equal(t, c.watches, ctrl.watches, "registered watches")
+		})
+	}
+}
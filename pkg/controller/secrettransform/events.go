@@ -0,0 +1,54 @@
+package secrettransform
+
+import (
+	goerrors "errors"
+	"strings"
+
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Reasons of events emitted for outputs
+const (
+	eventReasonCreated  = "Created"
+	eventReasonUpdated  = "Updated"
+	eventReasonDeleted  = "Deleted"
+	eventReasonOrphaned = "Orphaned"
+	eventReasonReleased = "Released"
+	// eventReasonOutputWarning reports a problem with an output that has been written, e.g. an expiring CA
+	eventReasonOutputWarning = "OutputWarning"
+)
+
+// recordOutputEvent emits a Normal event for an operation applied to an output
+func (r *ReconcileSecretTransform) recordOutputEvent(cr transformObject, reason, kind, name string) {
+	r.recorder.Eventf(cr, corev1.EventTypeNormal, reason, "%s output %s %s", reason, kind, name)
+}
+
+// recordFailure emits a Warning event for the given error
+func (r *ReconcileSecretTransform) recordFailure(cr transformObject, reason status.ConditionReason, err error) {
+	r.recorder.Event(cr, corev1.EventTypeWarning, string(reason), eventMessage(err))
+}
+
+// redactedError is an error whose message may contain (secret) input values
+type redactedError interface {
+	error
+	Redacted() string
+}
+
+var (
+	_ redactedError = &transform.QueryError{}
+	_ redactedError = &transform.ResultError{}
+)
+
+// eventMessage returns the error message without query evaluation errors
+// and query results since those may contain (secret) input values
+func eventMessage(err error) string {
+	msg := err.Error()
+	for e := err; e != nil; e = goerrors.Unwrap(e) {
+		if r, ok := e.(redactedError); ok {
+			return strings.Replace(msg, r.Error(), r.Redacted(), 1)
+		}
+	}
+	return msg
+}
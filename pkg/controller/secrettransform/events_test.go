@@ -0,0 +1,62 @@
+package secrettransform
+
+import (
+	"testing"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventMessage(t *testing.T) {
+	secretValue := "s3cr3t/value"
+	scope := func() map[string]interface{} {
+		return map[string]interface{}{
+			"in": transform.InputMapFromStringMap(map[string]string{"key": secretValue}, nil),
+		}
+	}
+	for _, c := range []struct {
+		name     string
+		output   ktransformv1alpha1.Output
+		expected string
+	}{
+		{
+			"invalid key",
+			ktransformv1alpha1.Output{
+				Secret:             &ktransformv1alpha1.SecretOutput{Name: "a"},
+				DataTransformation: `{(.in.key.string): "x"}`,
+			},
+			"output 0: dataTransformation: invalid key",
+		},
+		{
+			"query error",
+			ktransformv1alpha1.Output{
+				Secret:         &ktransformv1alpha1.SecretOutput{Name: "a"},
+				Transformation: map[string]string{"k": `error(.in.key.string)`},
+			},
+			"output 0: k: query error(.in.key.string) failed",
+		},
+		{
+			"invalid object",
+			ktransformv1alpha1.Output{
+				Object: `"kind: [" + .in.key.string`,
+			},
+			"output 0: invalid object",
+		},
+		{
+			"invalid items",
+			ktransformv1alpha1.Output{
+				Secret: &ktransformv1alpha1.SecretOutput{},
+				Items:  `"- name: a\n  data: {" + .in.key.string + ": 1, " + .in.key.string + ": 2}"`,
+			},
+			"output 0: items: cannot decode query result",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			outputs := pipeline.Transform(scope, []ktransformv1alpha1.Output{c.output}, nil, nil)
+			require.Error(t, outputs[0].Err)
+			require.Equal(t, c.expected, eventMessage(outputs[0].Err))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package secrettransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const eventReasonGenerated = "Generated"
+
+var errStateNotControlled = errors.New("is not controlled by the transform")
+
+// generate generates the values specified by the SecretTransform, stores them within its state Secret
+// and returns a scope that contains the inputs as well as the generated values.
+func (r *ReconcileSecretTransform) generate(reqLogger logr.Logger, cr transformObject, inputs func() map[string]interface{}) (func() map[string]interface{}, error) {
+	if len(cr.GetSpec().Generate) == 0 {
+		return inputs, nil
+	}
+	state := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      pipeline.StateSecretName(cr.GetName()),
+		Namespace: cr.GetNamespace(),
+	}}
+	var generated []string
+	op, err := controllerutil.CreateOrUpdate(context.TODO(), r.client, state, func() (err error) {
+		if !state.CreationTimestamp.IsZero() && !metav1.IsControlledBy(state, cr) {
+			// do not adopt (and overwrite) a Secret that has been created by somebody else
+			return errStateNotControlled
+		}
+		if generated, err = pipeline.Generate(cr.GetSpec().Input, cr.GetSpec().Generate, state); err != nil {
+			return err
+		}
+		return controllerutil.SetControllerReference(cr, state, r.scheme)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("state secret %s: %w", state.Name, err)
+	}
+	if op != controllerutil.OperationResultNone {
+		logOperation(reqLogger, "Wrote state", state)
+	}
+	if len(generated) > 0 {
+		r.recorder.Eventf(cr, corev1.EventTypeNormal, eventReasonGenerated, "Generated %s", strings.Join(generated, ", "))
+	}
+	return pipeline.GeneratedScope(inputs, cr.GetSpec().Generate, state)
+}
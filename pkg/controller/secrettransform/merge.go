@@ -0,0 +1,114 @@
+package secrettransform
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// fieldManager returns the server-side apply field manager of a SecretTransform.
+// Each SecretTransform uses its own field manager in order to allow
+// multiple SecretTransforms to merge keys into the same object.
+func fieldManager(cr transformObject) string {
+	if cr.GetNamespace() == "" {
+		return fmt.Sprintf("ktransform/%s", cr.GetName())
+	}
+	return fmt.Sprintf("ktransform/%s/%s", cr.GetNamespace(), cr.GetName())
+}
+
+// mergeOutput server-side applies the transformed keys to an existing (possibly externally owned) object.
+// The SecretTransform does not become the object's controller but refers to itself
+// using an annotation in order to be reconciled when the object changes.
+// It fails when the object does not exist.
+func (r *ReconcileSecretTransform) mergeOutput(cr transformObject, res *transformedResource) (controllerutil.OperationResult, error) {
+	key := types.NamespacedName{Name: res.Resource.GetName(), Namespace: res.Resource.GetNamespace()}
+	err := r.client.Get(context.TODO(), key, res.Resource)
+	if err != nil {
+		return controllerutil.OperationResultNone, fmt.Errorf("merge target: %w", err)
+	}
+	resourceVersion := res.Resource.GetResourceVersion()
+	if err = res.Apply(); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	gvk, err := apiutil.GVKForObject(res.Resource, r.scheme)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	patch, err := pipeline.MergePatch(res.Resource, gvk)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	annotations := patch.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	k, v := mergedByAnnotation(cr)
+	annotations[k] = v
+	patch.SetAnnotations(annotations)
+	err = r.client.Patch(context.TODO(), patch, client.Apply, client.FieldOwner(fieldManager(cr)), client.ForceOwnership)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if patch.GetResourceVersion() != resourceVersion {
+		return controllerutil.OperationResultUpdated, nil
+	}
+	return controllerutil.OperationResultNone, nil
+}
+
+// applyOutput server-side applies a transformed object that is controlled by the SecretTransform.
+// Fields, labels and annotations that are not returned by the transformation anymore are removed
+// while fields that are set by others, e.g. defaults, are kept.
+func (r *ReconcileSecretTransform) applyOutput(cr transformObject, res *transformedResource) (controllerutil.OperationResult, error) {
+	key := types.NamespacedName{Name: res.Resource.GetName(), Namespace: res.Resource.GetNamespace()}
+	created := false
+	err := r.client.Get(context.TODO(), key, res.Resource)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		}
+		created = true
+	}
+	resourceVersion := res.Resource.GetResourceVersion()
+	// Fail when the existing object is controlled by another owner
+	if err = r.setOutputController(cr, res.Resource.DeepCopyObject().(metav1.Object)); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if err = res.Apply(); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if err = r.setOutputController(cr, res.Resource); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	err = r.client.Patch(context.TODO(), res.Resource, client.Apply, client.FieldOwner(fieldManager(cr)), client.ForceOwnership)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	switch {
+	case created:
+		return controllerutil.OperationResultCreated, nil
+	case res.Resource.GetResourceVersion() != resourceVersion:
+		return controllerutil.OperationResultUpdated, nil
+	}
+	return controllerutil.OperationResultNone, nil
+}
+
+// releaseOutput removes the keys, labels and annotations that have been merged into an object
+// including the merged-by annotation
+func (r *ReconcileSecretTransform) releaseOutput(log logr.Logger, cr transformObject, obj *unstructured.Unstructured) error {
+	patch := pipeline.ReleasePatch(obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+	logOperation(log, "Releasing output", obj)
+	err := r.client.Patch(context.TODO(), patch, client.Apply, client.FieldOwner(fieldManager(cr)), client.ForceOwnership)
+	if err == nil {
+		r.recordOutputEvent(cr, eventReasonReleased, obj.GetKind(), obj.GetName())
+	}
+	return err
+}
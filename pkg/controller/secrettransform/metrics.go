@@ -0,0 +1,105 @@
+package secrettransform
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsNamespace = "ktransform"
+	// resultSynced is the reconcile result label value of a successful reconciliation
+	resultSynced = "Synced"
+)
+
+var (
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_duration_seconds",
+		Help:      "Duration of the evaluation of a single query of a SecretTransform",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"namespace", "name"})
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_total",
+		Help:      "Number of SecretTransform reconciliations by result (Synced or the condition reason)",
+	}, []string{"result"})
+	managedInputs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_inputs",
+		Help:      "Number of objects a SecretTransform reads",
+	}, []string{"namespace", "name"})
+	managedOutputs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_outputs",
+		Help:      "Number of objects a SecretTransform writes",
+	}, []string{"namespace", "name"})
+	outputBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "output_bytes",
+		Help:      "Size of the data written into an output",
+	}, []string{"namespace", "name", "output_kind", "output_namespace", "output_name"})
+
+	queryCacheHits = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_cache_hits_total",
+		Help:      "Number of compiled jq queries that have been read from the cache",
+	}, func() float64 {
+		hits, _ := transform.DefaultQueryCache.Stats()
+		return float64(hits)
+	})
+	queryCacheMisses = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_cache_misses_total",
+		Help:      "Number of jq queries that have been compiled since they were not cached",
+	}, func() float64 {
+		_, misses := transform.DefaultQueryCache.Stats()
+		return float64(misses)
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(queryDuration, reconcileTotal, managedInputs, managedOutputs, outputBytes, queryCacheHits, queryCacheMisses)
+}
+
+// queryDurationObserver returns a func that records the duration of a query evaluation of the given transform
+func queryDurationObserver(cr transformObject) func(time.Duration) {
+	observer := queryDuration.WithLabelValues(cr.GetNamespace(), cr.GetName())
+	return func(d time.Duration) {
+		observer.Observe(d.Seconds())
+	}
+}
+
+// deleteMetrics removes the metrics of a deleted SecretTransform
+func deleteMetrics(cr transformObject) {
+	queryDuration.DeleteLabelValues(cr.GetNamespace(), cr.GetName())
+	managedInputs.DeleteLabelValues(cr.GetNamespace(), cr.GetName())
+	managedOutputs.DeleteLabelValues(cr.GetNamespace(), cr.GetName())
+	for _, o := range cr.GetStatus().Outputs {
+		outputBytes.DeleteLabelValues(cr.GetNamespace(), cr.GetName(), o.Kind, outputNamespace(cr, o), o.Name)
+	}
+}
+
+// dataSize returns the size of the data of a written output
+func dataSize(o pipeline.Resource) (size int) {
+	switch c := o.(type) {
+	case *corev1.Secret:
+		for _, v := range c.Data {
+			size += len(v)
+		}
+	case *corev1.ConfigMap:
+		for _, v := range c.Data {
+			size += len(v)
+		}
+	case *unstructured.Unstructured:
+		b, _ := json.Marshal(c.Object)
+		size = len(b)
+	}
+	return
+}
@@ -0,0 +1,168 @@
+package secrettransform
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// annotationOwner refers to the SecretTransform that controls an output
+// written to another namespace since ownerReferences cannot cross namespaces
+const annotationOwner = "ktransform.mgoltzsche.github.com/owner"
+
+func ownerAnnotationValue(cr transformObject) string {
+	return types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}.String()
+}
+
+// setOutputOwner marks the object as controlled by the SecretTransform.
+// It fails when the object is controlled by another SecretTransform already.
+func setOutputOwner(cr transformObject, o metav1.Object) error {
+	owner := ownerAnnotationValue(cr)
+	a := o.GetAnnotations()
+	if current := a[annotationOwner]; current != "" && current != owner {
+		return fmt.Errorf("object %s/%s is already owned by %s", o.GetNamespace(), o.GetName(), current)
+	}
+	if a == nil {
+		a = map[string]string{}
+	}
+	a[annotationOwner] = owner
+	o.SetAnnotations(a)
+	return nil
+}
+
+// isOutputOwner returns true if the object is controlled by the SecretTransform
+func isOutputOwner(cr transformObject, o metav1.Object) bool {
+	if o.GetNamespace() == cr.GetNamespace() {
+		for _, ref := range o.GetOwnerReferences() {
+			if ref.UID == cr.GetUID() && ref.Controller != nil && *ref.Controller {
+				return true
+			}
+		}
+		return false
+	}
+	return o.GetAnnotations()[annotationOwner] == ownerAnnotationValue(cr)
+}
+
+// removeOutputOwner removes the SecretTransform from the object's ownerReferences or owner annotation
+func removeOutputOwner(cr transformObject, o metav1.Object) {
+	if o.GetNamespace() == cr.GetNamespace() {
+		refs := o.GetOwnerReferences()
+		for i, ref := range refs {
+			if ref.UID == cr.GetUID() {
+				o.SetOwnerReferences(append(refs[:i], refs[i+1:]...))
+				return
+			}
+		}
+		return
+	}
+	a := o.GetAnnotations()
+	delete(a, annotationOwner)
+	o.SetAnnotations(a)
+}
+
+// enqueueRequestForOutputOwner enqueues a request for the SecretTransform (or ClusterSecretTransform)
+// that is referred to by the owner annotation of the changed object
+func enqueueRequestForOutputOwner(clusterScoped bool) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			l := strings.SplitN(o.Meta.GetAnnotations()[annotationOwner], "/", 2)
+			if len(l) != 2 || l[1] == "" || (l[0] == "") != clusterScoped {
+				return nil
+			}
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: l[0], Name: l[1]}}}
+		}),
+	}
+}
+
+// annotationMergedByPrefix prefixes the annotations that refer to the transforms that merged keys into an object.
+// Since multiple transforms may merge keys into the same object
+// each annotation's name is suffixed with a hash of the transform's namespace and name.
+const annotationMergedByPrefix = "ktransform.mgoltzsche.github.com/merged-by-"
+
+// mergedByAnnotation returns the annotation that refers to the transform that merged keys into an object.
+// Its value is the transform's namespace and name or only its name if it is cluster-scoped.
+func mergedByAnnotation(cr transformObject) (key, value string) {
+	value = cr.GetName()
+	if cr.GetNamespace() != "" {
+		value = cr.GetNamespace() + "/" + value
+	}
+	h := sha256.Sum256([]byte(value))
+	return annotationMergedByPrefix + hex.EncodeToString(h[:16]), value
+}
+
+// enqueueRequestsForMergingTransforms enqueues a request for each SecretTransform (or ClusterSecretTransform)
+// that is referred to by a merged-by annotation of the changed object
+func enqueueRequestsForMergingTransforms(clusterScoped bool) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) (r []reconcile.Request) {
+			for k, v := range o.Meta.GetAnnotations() {
+				if !strings.HasPrefix(k, annotationMergedByPrefix) || v == "" {
+					continue
+				}
+				l := strings.SplitN(v, "/", 2)
+				switch {
+				case len(l) == 1 && clusterScoped:
+					r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Name: l[0]}})
+				case len(l) == 2 && !clusterScoped && l[0] != "" && l[1] != "":
+					r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: l[0], Name: l[1]}})
+				}
+			}
+			return
+		}),
+	}
+}
+
+// indexFieldNamespaceSelector indexes the transforms that have an output namespaceSelector
+const indexFieldNamespaceSelector = "spec.output.namespaceSelector"
+
+// indexNamespaceSelectors registers the index that contains the transforms
+// with an output namespaceSelector
+func indexNamespaceSelectors(indexer client.FieldIndexer, kind *transformKind) error {
+	return indexer.IndexField(context.TODO(), kind.New(), indexFieldNamespaceSelector, func(o runtime.Object) []string {
+		for _, out := range o.(transformObject).GetSpec().Output {
+			if out.NamespaceSelector != nil {
+				return []string{"true"}
+			}
+		}
+		return nil
+	})
+}
+
+// enqueueRequestsForNamespaceSelectingTransforms enqueues a request for each transform of the given kind
+// with an output namespaceSelector that matches the changed namespace
+// (before or after the change) in order to write or prune its outputs.
+// Only the transforms with a namespaceSelector are considered.
+func enqueueRequestsForNamespaceSelectingTransforms(c client.Client, kind *transformKind) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) (r []reconcile.Request) {
+			l, err := kind.List(c, client.MatchingFields{indexFieldNamespaceSelector: "true"})
+			if err != nil {
+				log.Error(err, "failed to list "+kind.Kind+"s")
+				return nil
+			}
+			for _, cr := range l {
+				for _, out := range cr.GetSpec().Output {
+					if out.NamespaceSelector == nil {
+						continue
+					}
+					sel, err := metav1.LabelSelectorAsSelector(out.NamespaceSelector)
+					if err == nil && sel.Matches(labels.Set(o.Meta.GetLabels())) {
+						r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}})
+						break
+					}
+				}
+			}
+			return
+		}),
+	}
+}
@@ -0,0 +1,57 @@
+package secrettransform
+
+import (
+	"testing"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestEnqueueRequestsForMergingTransforms(t *testing.T) {
+	cr := &ktransformv1alpha1.SecretTransform{}
+	cr.Namespace = "myns"
+	cr.Name = "mytransform"
+	clusterCR := &ktransformv1alpha1.ClusterSecretTransform{}
+	clusterCR.Name = "myclustertransform"
+	sec := &corev1.Secret{}
+	sec.Namespace = "otherns"
+	sec.Name = "helm-secret"
+	sec.Annotations = map[string]string{"meta.helm.sh/release-name": "myrelease"}
+	for _, o := range []transformObject{cr, clusterCR} {
+		k, v := mergedByAnnotation(o)
+		require.Empty(t, validation.IsQualifiedName(k), "annotation %q", k)
+		sec.Annotations[k] = v
+	}
+	mapObject := handler.MapObject{Meta: sec, Object: sec}
+
+	h := enqueueRequestsForMergingTransforms(false).(*handler.EnqueueRequestsFromMapFunc)
+	require.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "myns", Name: "mytransform"}}}, h.ToRequests.Map(mapObject))
+	h = enqueueRequestsForMergingTransforms(true).(*handler.EnqueueRequestsFromMapFunc)
+	require.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "myclustertransform"}}}, h.ToRequests.Map(mapObject))
+}
+
+func TestMergeOutputMissingTarget(t *testing.T) {
+	r := &ReconcileSecretTransform{
+		client:   fake.NewFakeClientWithScheme(clientgoscheme.Scheme),
+		scheme:   clientgoscheme.Scheme,
+		recorder: record.NewFakeRecorder(10),
+	}
+	cr := &ktransformv1alpha1.SecretTransform{}
+	cr.Namespace = "myns"
+	cr.Name = "mytransform"
+	sec := &corev1.Secret{}
+	sec.Namespace = "myns"
+	sec.Name = "missing"
+	res := &transformedResource{Output: &pipeline.Output{Resource: sec, Apply: func() error { return nil }}}
+	_, err := r.mergeOutput(cr, res)
+	require.True(t, isNotFound(err), "should fail with NotFound when the target does not exist but returned %v", err)
+}
@@ -0,0 +1,87 @@
+package secrettransform
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/kubernetes/pkg/util/hash"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// outputStatuses derives the status of the transformed outputs from the last status.
+// The status of an output that could not be transformed is taken over from the
+// last status (if any) in order to keep track of the previously written object.
+func (r *ReconcileSecretTransform) outputStatuses(namespace string, lastOutputs []ktransformv1alpha1.OutputStatus, transformed []*transformedResource) (outputs []ktransformv1alpha1.OutputStatus, err error) {
+	last := map[string]ktransformv1alpha1.OutputStatus{}
+	for _, o := range lastOutputs {
+		last[outputKey(o)] = o
+	}
+	for _, res := range transformed {
+		if res.Err != nil {
+			found := false
+			for _, o := range lastOutputs {
+				if o.Index == res.Index && o.Name != "" {
+					setOutputError(&o, res)
+					outputs = append(outputs, o)
+					found = true
+				}
+			}
+			if !found {
+				o := ktransformv1alpha1.OutputStatus{Index: res.Index, PrunePolicy: res.PrunePolicy, WriteMode: res.WriteMode}
+				setOutputError(&o, res)
+				outputs = append(outputs, o)
+			}
+			continue
+		}
+		gvk, err := apiutil.GVKForObject(res.Resource, r.scheme)
+		if err != nil {
+			return nil, err
+		}
+		o := ktransformv1alpha1.OutputStatus{Index: res.Index, Name: res.Resource.GetName()}
+		if ns := res.Resource.GetNamespace(); ns != namespace {
+			o.Namespace = ns
+		}
+		o.APIVersion, o.Kind = gvk.ToAPIVersionAndKind()
+		if l, ok := last[outputKey(o)]; ok {
+			o.Hash = l.Hash
+			o.LastWriteTime = l.LastWriteTime
+		}
+		o.PrunePolicy = res.PrunePolicy
+		o.WriteMode = res.WriteMode
+		res.status = len(outputs)
+		outputs = append(outputs, o)
+	}
+	return
+}
+
+func setOutputError(o *ktransformv1alpha1.OutputStatus, res *transformedResource) {
+	o.Index = res.Index
+	o.Reason = res.Reason
+	o.Message = res.Err.Error()
+}
+
+// dataHash returns a hash of the data of a written output
+func dataHash(o pipeline.Resource) string {
+	var data interface{}
+	switch c := o.(type) {
+	case *corev1.Secret:
+		data = c.Data
+	case *corev1.ConfigMap:
+		data = c.Data
+	case *unstructured.Unstructured:
+		m := map[string]interface{}{}
+		for k, v := range c.Object {
+			if k != "metadata" && k != "status" {
+				m[k] = v
+			}
+		}
+		data = m
+	}
+	h := sha256.New()
+	hash.DeepHashObject(h, data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
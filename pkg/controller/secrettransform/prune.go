@@ -0,0 +1,83 @@
+package secrettransform
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// obsoleteOutputs returns the outputs of lastOutputs that are not contained in outputs
+func obsoleteOutputs(lastOutputs, outputs []ktransformv1alpha1.OutputStatus) (obsolete []ktransformv1alpha1.OutputStatus) {
+	keys := map[string]struct{}{}
+	for _, o := range outputs {
+		keys[outputKey(o)] = struct{}{}
+	}
+	for _, o := range lastOutputs {
+		if _, ok := keys[outputKey(o)]; !ok && o.Name != "" {
+			obsolete = append(obsolete, o)
+		}
+	}
+	return
+}
+
+func outputKey(o ktransformv1alpha1.OutputStatus) string {
+	gk := schema.FromAPIVersionAndKind(o.APIVersion, o.Kind).GroupKind()
+	return gk.String() + "/" + o.Namespace + "/" + o.Name
+}
+
+// outputNamespace returns the namespace of the object an output status refers to
+func outputNamespace(cr transformObject, o ktransformv1alpha1.OutputStatus) string {
+	if o.Namespace != "" {
+		return o.Namespace
+	}
+	return cr.GetNamespace()
+}
+
+// pruneOutput deletes or orphans an output that is not specified anymore.
+// Objects that are not controlled by the SecretTransform are left untouched
+// unless the output has been merged into them: in that case the merged keys are removed.
+func (r *ReconcileSecretTransform) pruneOutput(log logr.Logger, cr transformObject, o ktransformv1alpha1.OutputStatus) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(o.APIVersion)
+	obj.SetKind(o.Kind)
+	namespace := outputNamespace(cr, o)
+	outputBytes.DeleteLabelValues(cr.GetNamespace(), cr.GetName(), o.Kind, namespace, o.Name)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: o.Name, Namespace: namespace}, obj)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if o.WriteMode == ktransformv1alpha1.WriteModeMerge {
+		if o.PrunePolicy == ktransformv1alpha1.PrunePolicyOrphan {
+			return nil
+		}
+		return r.releaseOutput(log, cr, obj)
+	}
+	if !isOutputOwner(cr, obj) {
+		return nil
+	}
+	if o.PrunePolicy == ktransformv1alpha1.PrunePolicyOrphan {
+		removeOutputOwner(cr, obj)
+		logOperation(log, "Orphaning output", obj)
+		err = r.client.Update(context.TODO(), obj)
+		if err == nil {
+			r.recordOutputEvent(cr, eventReasonOrphaned, o.Kind, o.Name)
+		}
+		return err
+	}
+	logOperation(log, "Deleting output", obj)
+	err = r.client.Delete(context.TODO(), obj)
+	if err == nil {
+		r.recordOutputEvent(cr, eventReasonDeleted, o.Kind, o.Name)
+	} else if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
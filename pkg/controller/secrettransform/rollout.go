@@ -0,0 +1,71 @@
+package secrettransform
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	eventReasonRolledOut = "RolledOut"
+	// annotationNameMaxLength is the maximum length of the name part of an annotation key
+	annotationNameMaxLength = 63
+)
+
+// rolloutAnnotation returns the pod template annotation that contains the hash of an output.
+// Since the name part of an annotation key is limited to 63 characters
+// longer output names are truncated and suffixed with a hash of the name.
+func rolloutAnnotation(kind, name string) string {
+	if len(name) > annotationNameMaxLength {
+		h := sha256.Sum256([]byte(name))
+		suffix := hex.EncodeToString(h[:6])
+		name = name[:annotationNameMaxLength-len(suffix)-1] + "-" + suffix
+	}
+	return fmt.Sprintf("%s.hash.%s/%s", strings.ToLower(kind), ktransformv1alpha1.SchemeGroupVersion.Group, name)
+}
+
+// rollout restarts the output's rollout targets by setting the output's hash as pod template annotation.
+// A target is only restarted when the output has been updated
+// or when its annotation refers to another hash since a previous rollout failed.
+// Targets that don't exist are skipped.
+func (r *ReconcileSecretTransform) rollout(log logr.Logger, cr transformObject, res *transformedResource, kind string, updated bool) error {
+	hash := dataHash(res.Resource)
+	annotation := rolloutAnnotation(kind, res.Resource.GetName())
+	for _, target := range cr.GetSpec().Output[res.Index].RolloutTargets {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("apps/v1")
+		obj.SetKind(string(target.Kind))
+		key := types.NamespacedName{Name: target.Name, Namespace: res.Resource.GetNamespace()}
+		if err := r.client.Get(context.TODO(), key, obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("rollout %s %s: %w", target.Kind, target.Name, err)
+		}
+		current, _, _ := unstructured.NestedString(obj.Object, "spec", "template", "metadata", "annotations", annotation)
+		if current == hash || (!updated && current == "") {
+			continue
+		}
+		patch := client.MergeFrom(obj.DeepCopy())
+		err := unstructured.SetNestedField(obj.Object, hash, "spec", "template", "metadata", "annotations", annotation)
+		if err != nil {
+			return fmt.Errorf("rollout %s %s: %w", target.Kind, target.Name, err)
+		}
+		logOperation(log, "Restarting", obj)
+		if err = r.client.Patch(context.TODO(), obj, patch); err != nil {
+			return fmt.Errorf("rollout %s %s: %w", target.Kind, target.Name, err)
+		}
+		r.recorder.Eventf(cr, corev1.EventTypeNormal, eventReasonRolledOut, "Restarted %s %s since output %s %s changed", target.Kind, target.Name, kind, res.Resource.GetName())
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package secrettransform
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestRolloutAnnotation(t *testing.T) {
+	require.Equal(t, "secret.hash.ktransform.mgoltzsche.github.com/myapp", rolloutAnnotation("Secret", "myapp"))
+	longName := strings.Repeat("a", 253)
+	a1 := rolloutAnnotation("ConfigMap", longName)
+	a2 := rolloutAnnotation("ConfigMap", longName[:252]+"b")
+	require.Empty(t, validation.IsQualifiedName(a1), "annotation %q", a1)
+	require.NotEqual(t, a1, a2, "annotations of long names with the same prefix")
+}
+
+func TestRollout(t *testing.T) {
+	deployment := &appsv1.Deployment{}
+	deployment.Namespace = "myns"
+	deployment.Name = "myapp"
+	client := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, deployment)
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileSecretTransform{client: client, scheme: clientgoscheme.Scheme, recorder: recorder}
+	cr := &ktransformv1alpha1.SecretTransform{}
+	cr.Namespace = "myns"
+	cr.Name = "mytransform"
+	cr.Spec.Output = []ktransformv1alpha1.Output{{RolloutTargets: []ktransformv1alpha1.RolloutTarget{
+		{Kind: ktransformv1alpha1.RolloutTargetDeployment, Name: "myapp"},
+		{Kind: ktransformv1alpha1.RolloutTargetDeployment, Name: "missing"},
+	}}}
+	sec := &corev1.Secret{Data: map[string][]byte{"k": []byte("v1")}}
+	sec.Namespace = "myns"
+	sec.Name = "myapp-credentials"
+	res := &transformedResource{Output: &pipeline.Output{Resource: sec}}
+	annotation := rolloutAnnotation("Secret", sec.Name)
+	podAnnotation := func() string {
+		d := &appsv1.Deployment{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Namespace: "myns", Name: "myapp"}, d))
+		return d.Spec.Template.Annotations[annotation]
+	}
+	log := logf.Log
+
+	require.NoError(t, r.rollout(log, cr, res, "Secret", false))
+	require.Empty(t, podAnnotation(), "should not restart target when output has not been updated")
+
+	require.NoError(t, r.rollout(log, cr, res, "Secret", true))
+	hash := podAnnotation()
+	require.Equal(t, dataHash(sec), hash, "should set hash when output has been updated")
+	require.Len(t, recorder.Events, 1, "events")
+
+	sec.Data["k"] = []byte("v2")
+	require.NoError(t, r.rollout(log, cr, res, "Secret", false))
+	require.Equal(t, dataHash(sec), podAnnotation(), "should restart target whose annotation refers to another hash")
+	require.NotEqual(t, hash, podAnnotation(), "hash should change")
+
+	require.NoError(t, r.rollout(log, cr, res, "Secret", true))
+	require.Len(t, recorder.Events, 2, "should not restart target again when hash is unchanged")
+}
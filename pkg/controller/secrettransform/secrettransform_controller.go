@@ -6,19 +6,22 @@ import (
 	goerrors "errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
 	"github.com/mgoltzsche/ktransform/pkg/backrefs"
-	"github.com/mgoltzsche/ktransform/pkg/transform"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
 	"github.com/operator-framework/operator-sdk/pkg/status"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/util/hash"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -31,50 +34,113 @@ import (
 )
 
 var (
-	log                      = logf.Log.WithName("controller_secrettransform")
-	errAmbiguousResource     = goerrors.New("configMap or secret required but both specified")
-	errUnspecifiedResource   = goerrors.New("neither configMap or secret specified")
-	errMissingTransformation = goerrors.New("no transformation specified")
-	finalizer                = "ktransform.mgoltzsche.github.com/clearbackrefs"
+	log       = logf.Log.WithName("controller_secrettransform")
+	finalizer = "ktransform.mgoltzsche.github.com/clearbackrefs"
 )
 
-const (
-	jqQueryTimeout = time.Second * 5
-)
-
-func isSpecError(err error) bool {
-	u := goerrors.Unwrap(err)
-	return u == errAmbiguousResource || u == errUnspecifiedResource || u == errMissingTransformation
+// Options configures the SecretTransform and ClusterSecretTransform controllers
+type Options struct {
+	// AllowedInputNamespaces lists the namespaces a SecretTransform may read
+	// inputs from in addition to its own namespace. "*" allows any namespace.
+	AllowedInputNamespaces []string
+	// AllowedOutputNamespaces lists the namespaces a SecretTransform may write
+	// outputs to in addition to its own namespace. "*" allows any namespace.
+	AllowedOutputNamespaces []string
+	// ClusterScoped enables the ClusterSecretTransform controller.
+	// It requires the operator to watch all namespaces.
+	ClusterScoped bool
+	// WatchNamespaces lists the namespaces the manager's cache watches.
+	// Empty means all namespaces.
+	WatchNamespaces []string
 }
 
 // Add creates a new SecretTransform Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
-	refHandler := backrefs.NewBackReferencesHandler(mgr.GetClient(), backrefs.OwnerReferences())
+func Add(mgr manager.Manager, opts Options) error {
+	return add(mgr, opts, secretTransformKind)
+}
+
+// AddCluster creates a new ClusterSecretTransform Controller and adds it to the Manager
+// if the operator is cluster-scoped.
+// A ClusterSecretTransform may read inputs from and write outputs to any namespace.
+func AddCluster(mgr manager.Manager, opts Options) error {
+	if !opts.ClusterScoped {
+		log.Info("ClusterSecretTransform controller disabled since the operator does not watch all namespaces")
+		return nil
+	}
+	opts.AllowedInputNamespaces = []string{"*"}
+	opts.AllowedOutputNamespaces = []string{"*"}
+	return add(mgr, opts, clusterSecretTransformKind)
+}
+
+func add(mgr manager.Manager, opts Options, kind *transformKind) error {
+	apiGroup := ktransformv1alpha1.SchemeGroupVersion.Group
+	refHandler := backrefs.NewBackReferencesHandler(mgr.GetClient(), backrefs.AnnotationOrOwnerReferences(apiGroup))
+	name := strings.ToLower(kind.Kind) + "-controller"
 	r := &ReconcileSecretTransform{
 		client:     mgr.GetClient(),
 		scheme:     mgr.GetScheme(),
 		restMapper: mgr.GetRESTMapper(),
-		refhandler: refHandler}
+		refhandler: refHandler,
+		recorder:   mgr.GetEventRecorderFor(name),
+		kind:       kind,
+		options:    opts}
 
 	// Create a new controller
-	c, err := controller.New("secrettransform-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New(name, mgr, controller.Options{Reconciler: r})
 	if err != nil {
 		return err
 	}
 
-	// Watch for changes to primary resource SecretTransform
-	err = c.Watch(&source.Kind{Type: &ktransformv1alpha1.SecretTransform{}}, &handler.EnqueueRequestForObject{})
+	// Watch for changes to primary resource
+	err = c.Watch(&source.Kind{Type: kind.New()}, &handler.EnqueueRequestForObject{})
 	if err != nil {
 		return err
 	}
 
-	// Watch for changes to secondary resources
-	for _, res := range []runtime.Object{&corev1.Secret{}, &corev1.ConfigMap{}} {
-		err = c.Watch(&source.Kind{Type: res}, &handler.EnqueueRequestForOwner{
+	// Handlers that map changed secondary resources to the transforms referring to them
+	handlers := []handler.EventHandler{
+		// inputs in other namespaces
+		kind.enqueueRequestForInputReference(),
+		// outputs in other namespaces
+		enqueueRequestForOutputOwner(kind.ClusterScoped),
+		// objects outputs have been merged into
+		enqueueRequestsForMergingTransforms(kind.ClusterScoped),
+	}
+	if !kind.ClusterScoped {
+		// inputs and outputs within the same namespace
+		handlers = append(handlers, &handler.EnqueueRequestForOwner{
 			IsController: false,
-			OwnerType:    &ktransformv1alpha1.SecretTransform{},
+			OwnerType:    kind.New(),
 		})
+	}
+	r.watches = newDynamicWatches(c, mgr.GetAPIReader(), handlers, opts.WatchNamespaces)
+
+	if err = indexInputSelectors(mgr.GetFieldIndexer(), kind); err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resources
+	for _, res := range []runtime.Object{&corev1.Secret{}, &corev1.ConfigMap{}} {
+		for _, h := range handlers {
+			if err = c.Watch(&source.Kind{Type: res}, h); err != nil {
+				return err
+			}
+		}
+		// Watch objects that (start to) match an input selector
+		err = c.Watch(&source.Kind{Type: res}, enqueueRequestsForSelectingTransforms(mgr.GetClient(), kind))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Watch namespaces that (start to) match an output's namespace selector.
+	// Requires permission to watch namespaces which is only needed when outputs may be written to other namespaces.
+	if len(opts.AllowedOutputNamespaces) > 0 {
+		if err = indexNamespaceSelectors(mgr.GetFieldIndexer(), kind); err != nil {
+			return err
+		}
+		err = c.Watch(&source.Kind{Type: &corev1.Namespace{}}, enqueueRequestsForNamespaceSelectingTransforms(mgr.GetClient(), kind))
 		if err != nil {
 			return err
 		}
@@ -86,7 +152,7 @@ func Add(mgr manager.Manager) error {
 // blank assignment to verify that ReconcileSecretTransform implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileSecretTransform{}
 
-// ReconcileSecretTransform reconciles a SecretTransform object
+// ReconcileSecretTransform reconciles a SecretTransform or ClusterSecretTransform object
 type ReconcileSecretTransform struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
@@ -94,6 +160,10 @@ type ReconcileSecretTransform struct {
 	scheme     *runtime.Scheme
 	restMapper meta.RESTMapper
 	refhandler *backrefs.BackReferencesHandler
+	watches    *dynamicWatches
+	recorder   record.EventRecorder
+	kind       *transformKind
+	options    Options
 }
 
 // Reconcile reads that state of the cluster for a SecretTransform object and makes changes based on the state read
@@ -103,10 +173,10 @@ type ReconcileSecretTransform struct {
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileSecretTransform) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
-	reqLogger.Info("Reconciling SecretTransform")
+	reqLogger.Info("Reconciling " + r.kind.Kind)
 
 	// Fetch the SecretTransform instance
-	cr := &ktransformv1alpha1.SecretTransform{}
+	cr := r.kind.New()
 	err := r.client.Get(context.TODO(), request.NamespacedName, cr)
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -123,12 +193,24 @@ func (r *ReconcileSecretTransform) Reconcile(request reconcile.Request) (reconci
 
 	// When marked as deleted finalize object: remove back references
 	isFinalizerPresent := hasFinalizer(cr, finalizer)
-	if !cr.ObjectMeta.DeletionTimestamp.IsZero() {
+	if !cr.GetDeletionTimestamp().IsZero() {
 		if isFinalizerPresent {
-			reqLogger.Info("Finalizing " + cr.Kind)
+			reqLogger.Info("Finalizing " + r.kind.Kind)
+			// Remove the keys merged into objects that are not owned by the SecretTransform
+			// and delete outputs in other namespaces since those cannot be garbage collected
+			for _, o := range cr.GetStatus().Outputs {
+				if (o.WriteMode == ktransformv1alpha1.WriteModeMerge || o.Namespace != "") && o.Name != "" {
+					// pruned regardless of the prune policy as owned outputs are garbage collected
+					o.PrunePolicy = ktransformv1alpha1.PrunePolicyDelete
+					if err = r.pruneOutput(reqLogger, cr, o); err != nil {
+						reqLogger.Error(err, "failed to prune output", "finalizer", finalizer)
+						return reconcile.Result{}, err
+					}
+				}
+			}
 			err = r.refhandler.UpdateReferences(context.TODO(), reqLogger, refOwner, nil)
 			if err != nil {
-				reqLogger.Error(err, "finalizer %s failed to clean up ownerReferences", finalizer)
+				reqLogger.Error(err, "failed to clean up ownerReferences", "finalizer", finalizer)
 				return reconcile.Result{}, err
 			}
 			controllerutil.RemoveFinalizer(cr, finalizer)
@@ -136,6 +218,7 @@ func (r *ReconcileSecretTransform) Reconcile(request reconcile.Request) (reconci
 			if err != nil {
 				return reconcile.Result{}, err
 			}
+			deleteMetrics(cr)
 		}
 		return reconcile.Result{}, nil
 	}
@@ -145,66 +228,165 @@ func (r *ReconcileSecretTransform) Reconcile(request reconcile.Request) (reconci
 		controllerutil.AddFinalizer(cr, finalizer)
 		err := r.client.Update(context.TODO(), cr)
 		if err != nil {
-			r.setSyncStatus(cr, corev1.ConditionFalse, ktransformv1alpha1.ReasonFailed, err.Error())
+			r.setSyncFailure(cr, ktransformv1alpha1.ReasonFailed, err)
 			return reconcile.Result{}, err
 		}
 		// Stop here since update triggered another reconcile request anyway
 		return reconcile.Result{}, nil
 	}
 
+	// Validate kind specific constraints
+	if r.kind.Validate != nil {
+		if errs := r.kind.Validate(cr.GetSpec()); len(errs) > 0 {
+			err = r.setSyncFailure(cr, ktransformv1alpha1.ReasonInvalidSpec, errs.ToAggregate())
+			return reconcile.Result{}, err
+		}
+	}
+
 	// Fetch inputs
-	refs, scope, err := r.inputScopeFactory(cr.Namespace, cr.Spec.Input)
+	refs, scope, err := r.inputLoader().Load(cr.GetNamespace(), cr.GetSpec().Input)
 	if err != nil {
-		if errors.IsNotFound(goerrors.Unwrap(err)) {
-			err = r.setSyncStatus(cr, corev1.ConditionFalse, ktransformv1alpha1.ReasonMissingInput, err.Error())
+		if isNotFound(err) {
+			err = r.setSyncFailure(cr, ktransformv1alpha1.ReasonMissingInput, err)
 			return reconcile.Result{RequeueAfter: 30 * time.Second}, err
 		}
-		if isSpecError(err) {
-			err = r.setSyncStatus(cr, corev1.ConditionFalse, ktransformv1alpha1.ReasonInvalidSpec, err.Error())
+		if pipeline.IsSpecError(err) {
+			err = r.setSyncFailure(cr, ktransformv1alpha1.ReasonInvalidSpec, err)
 			return reconcile.Result{}, err
 		}
-		r.setSyncStatus(cr, corev1.ConditionFalse, ktransformv1alpha1.ReasonFailed, err.Error())
+		if isForbidden(err) {
+			r.setSyncFailure(cr, ktransformv1alpha1.ReasonForbidden, err)
+			return reconcile.Result{}, err
+		}
+		r.setSyncFailure(cr, ktransformv1alpha1.ReasonFailed, err)
+		return reconcile.Result{}, err
+	}
+
+	// Generate values
+	scope, err = r.generate(reqLogger, cr, scope)
+	if err != nil {
+		if pipeline.IsSpecError(err) {
+			err = r.setSyncFailure(cr, ktransformv1alpha1.ReasonInvalidSpec, err)
+			return reconcile.Result{}, err
+		}
+		if goerrors.Is(err, errStateNotControlled) {
+			r.setSyncFailure(cr, ktransformv1alpha1.ReasonFailedWrite, err)
+			return reconcile.Result{}, err
+		}
+		r.setSyncFailure(cr, ktransformv1alpha1.ReasonFailed, err)
 		return reconcile.Result{}, err
 	}
 
 	// Add CR as ownerReference to referenced Secrets/ConfigMaps
 	err = r.refhandler.UpdateReferences(context.TODO(), reqLogger, refOwner, refs)
 	if err != nil {
-		r.setSyncStatus(cr, corev1.ConditionFalse, ktransformv1alpha1.ReasonFailed, err.Error())
+		r.setSyncFailure(cr, ktransformv1alpha1.ReasonFailed, err)
 		return reconcile.Result{}, err
 	}
+	managedInputs.WithLabelValues(cr.GetNamespace(), cr.GetName()).Set(float64(len(refs)))
 
 	// Transform
-	transformed, err := transformedResources(scope, cr.Spec.Output)
+	transformed := transformedResources(scope, cr.GetSpec().Output, r.outputNamespaces(cr.GetNamespace()), queryDurationObserver(cr))
+	abortOnFailure := cr.GetSpec().FailurePolicy != ktransformv1alpha1.FailurePolicyContinue
+	outputs, err := r.outputStatuses(cr.GetNamespace(), cr.GetStatus().Outputs, transformed)
 	if err != nil {
-		err = r.setSyncStatus(cr, corev1.ConditionFalse, ktransformv1alpha1.ReasonInvalidSpec, err.Error())
-		return reconcile.Result{}, err // do not reconcile unless spec (or referenced resource) changes
+		r.setSyncFailure(cr, ktransformv1alpha1.ReasonFailed, err)
+		return reconcile.Result{}, err
 	}
-	applied := make([]runtime.Object, len(transformed))
-	for i, tr := range transformed {
-		applied[i] = tr.Resource
+	obsolete := obsoleteOutputs(cr.GetStatus().Outputs, outputs)
+	if abortOnFailure {
+		for _, res := range transformed {
+			if res.Err != nil {
+				// do not reconcile unless spec (or referenced resource) changes
+				return reconcile.Result{}, r.setOutputStatus(cr, append(outputs, obsolete...), res)
+			}
+		}
+	}
+
+	// Add new outputs to status before writing them
+	// (for consistency, to be able to prune them later)
+	if len(obsoleteOutputs(outputs, cr.GetStatus().Outputs)) > 0 {
+		cr.GetStatus().Outputs = append(outputs, obsolete...)
+		err = r.client.Status().Update(context.TODO(), cr)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
 	}
 
 	// Write output
+	applied := make([]runtime.Object, 0, len(transformed))
+	var failed *transformedResource
+	var writeErr error
+	var renewAt time.Time
 	for _, res := range transformed {
-		res.Resource.SetNamespace(cr.Namespace)
+		if res.Err != nil {
+			r.recordFailure(cr, res.Reason, res.Err)
+			if failed == nil {
+				failed = res
+			}
+			continue
+		}
 		var opRes controllerutil.OperationResult
-		opRes, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, res.Resource, func() error {
-			res.Apply()
-			return controllerutil.SetControllerReference(cr, res.Resource, r.scheme)
-		})
+		reason := ktransformv1alpha1.ReasonFailedWrite
+		opRes, err = r.writeOutput(cr, res)
+		if err == nil {
+			// Restart workloads that refer to the output
+			reason = ktransformv1alpha1.ReasonFailedRollout
+			err = r.rollout(reqLogger, cr, res, outputs[res.status].Kind, opRes == controllerutil.OperationResultUpdated)
+		}
 		if err != nil {
-			r.setSyncStatus(cr, corev1.ConditionFalse, ktransformv1alpha1.ReasonFailedWrite, err.Error())
-			return reconcile.Result{}, err
+			res.Err = err
+			res.Reason = reason
+			if isForbidden(err) {
+				res.Reason = ktransformv1alpha1.ReasonForbidden
+			}
+			setOutputError(&outputs[res.status], res)
+			if abortOnFailure {
+				r.setOutputStatus(cr, append(outputs, obsolete...), res)
+				return reconcile.Result{}, err
+			}
+			r.recordFailure(cr, res.Reason, err)
+			if failed == nil {
+				failed = res
+			}
+			writeErr = err
+			continue
+		}
+		applied = append(applied, res.Resource)
+		if !res.RenewAt.IsZero() && (renewAt.IsZero() || res.RenewAt.Before(renewAt)) {
+			renewAt = res.RenewAt
 		}
+		o := &outputs[res.status]
+		o.Hash = dataHash(res.Resource)
+		if res.Warning != "" {
+			r.recorder.Eventf(cr, corev1.EventTypeWarning, eventReasonOutputWarning, "Output %s %s: %s", o.Kind, o.Name, res.Warning)
+		}
+		outputBytes.WithLabelValues(cr.GetNamespace(), cr.GetName(), o.Kind, outputNamespace(cr, *o), o.Name).Set(float64(dataSize(res.Resource)))
 		switch opRes {
 		case controllerutil.OperationResultCreated:
 			logOperation(reqLogger, "Created output", res.Resource)
+			r.recordOutputEvent(cr, eventReasonCreated, o.Kind, o.Name)
+			o.LastWriteTime = &metav1.Time{Time: time.Now()}
 		case controllerutil.OperationResultUpdated:
 			logOperation(reqLogger, "Updated output", res.Resource)
+			r.recordOutputEvent(cr, eventReasonUpdated, o.Kind, o.Name)
+			o.LastWriteTime = &metav1.Time{Time: time.Now()}
 		}
 	}
 
+	// Prune outputs that are not specified anymore
+	for _, o := range obsolete {
+		err = r.pruneOutput(reqLogger, cr, o)
+		if err != nil {
+			err = fmt.Errorf("prune %s %s: %w", o.Kind, o.Name, err)
+			cr.GetStatus().Outputs = append(outputs, obsolete...)
+			r.setSyncFailure(cr, ktransformv1alpha1.ReasonFailedWrite, err)
+			return reconcile.Result{}, err
+		}
+	}
+
+	managedOutputs.WithLabelValues(cr.GetNamespace(), cr.GetName()).Set(float64(len(applied)))
+
 	// Update status
 	h := sha256.New()
 	hash.DeepHashObject(h, applied)
@@ -213,25 +395,112 @@ func (r *ReconcileSecretTransform) Reconcile(request reconcile.Request) (reconci
 		Type:   ktransformv1alpha1.ConditionSynced,
 		Status: corev1.ConditionTrue,
 	}
-	if cr.Status.Conditions.SetCondition(syncCond) ||
-		cr.Status.OutputHash != outputHash ||
-		cr.Status.ObservedGeneration != cr.Generation {
-		cr.Status.ObservedGeneration = cr.Generation
-		cr.Status.OutputHash = outputHash
+	if failed != nil {
+		syncCond.Status = corev1.ConditionFalse
+		syncCond.Reason = failed.Reason
+		syncCond.Message = failed.Err.Error()
+		reconcileTotal.WithLabelValues(string(failed.Reason)).Inc()
+	} else {
+		reconcileTotal.WithLabelValues(resultSynced).Inc()
+	}
+	if cr.GetStatus().Conditions.SetCondition(syncCond) ||
+		cr.GetStatus().OutputHash != outputHash ||
+		cr.GetStatus().ObservedGeneration != cr.GetGeneration() ||
+		!equality.Semantic.DeepEqual(cr.GetStatus().Outputs, outputs) {
+		cr.GetStatus().ObservedGeneration = cr.GetGeneration()
+		cr.GetStatus().OutputHash = outputHash
+		cr.GetStatus().Outputs = outputs
 		err = r.client.Status().Update(context.TODO(), cr)
-		return reconcile.Result{}, err
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	// Requeue failed writes and renew certificates before they expire
+	return reconcile.Result{RequeueAfter: requeueAfter(renewAt)}, writeErr
+}
+
+// requeueAfter returns the duration until the given renewal time or 0 if none is specified
+func requeueAfter(renewAt time.Time) time.Duration {
+	if renewAt.IsZero() {
+		return 0
+	}
+	d := time.Until(renewAt)
+	if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+// setOutputStatus sets the failed output's error as Synced condition and updates the output status
+func (r *ReconcileSecretTransform) setOutputStatus(cr transformObject, outputs []ktransformv1alpha1.OutputStatus, failed *transformedResource) error {
+	r.recordFailure(cr, failed.Reason, failed.Err)
+	reconcileTotal.WithLabelValues(string(failed.Reason)).Inc()
+	syncCond := status.Condition{
+		Type:    ktransformv1alpha1.ConditionSynced,
+		Status:  corev1.ConditionFalse,
+		Reason:  failed.Reason,
+		Message: failed.Err.Error(),
+	}
+	if cr.GetStatus().Conditions.SetCondition(syncCond) ||
+		cr.GetStatus().ObservedGeneration != cr.GetGeneration() ||
+		!equality.Semantic.DeepEqual(cr.GetStatus().Outputs, outputs) {
+		cr.GetStatus().ObservedGeneration = cr.GetGeneration()
+		cr.GetStatus().Outputs = outputs
+		return r.client.Status().Update(context.TODO(), cr)
+	}
+	return nil
+}
+
+func (r *ReconcileSecretTransform) writeOutput(cr transformObject, res *transformedResource) (controllerutil.OperationResult, error) {
+	if obj, ok := res.Resource.(*unstructured.Unstructured); ok {
+		// Watch generated kind to reconcile when an output is changed by another actor
+		err := r.watches.Watch(obj.GroupVersionKind())
+		if err != nil {
+			return controllerutil.OperationResultNone, fmt.Errorf("output %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		return r.applyOutput(cr, res)
+	}
+	if res.WriteMode == ktransformv1alpha1.WriteModeMerge {
+		return r.mergeOutput(cr, res)
+	}
+	return controllerutil.CreateOrUpdate(context.TODO(), r.client, res.Resource, func() error {
+		if err := res.Apply(); err != nil {
+			return err
+		}
+		return r.setOutputController(cr, res.Resource)
+	})
+}
+
+// setOutputController sets the SecretTransform as the output's controller.
+// Outputs in other namespaces refer to it using an annotation.
+func (r *ReconcileSecretTransform) setOutputController(cr transformObject, o metav1.Object) error {
+	if o.GetNamespace() != cr.GetNamespace() {
+		return setOutputOwner(cr, o)
 	}
-	return reconcile.Result{}, nil
+	return controllerutil.SetControllerReference(cr, o, r.scheme)
+}
+
+func isForbidden(err error) bool {
+	var statusErr *errors.StatusError
+	return goerrors.As(err, &statusErr) && errors.IsForbidden(statusErr)
+}
+
+func isNotFound(err error) bool {
+	var statusErr *errors.StatusError
+	return goerrors.As(err, &statusErr) && errors.IsNotFound(statusErr)
 }
 
 func logOperation(log logr.Logger, verb string, o metav1.Object) {
 	kind := reflect.TypeOf(o).Elem().Name()
+	if u, ok := o.(*unstructured.Unstructured); ok {
+		kind = u.GetKind()
+	}
 	msg := fmt.Sprintf("%s %s", verb, kind)
 	log.Info(msg, kind+".Namespace", o.GetNamespace(), kind+".Name", o.GetName())
 }
 
-func hasFinalizer(cr *ktransformv1alpha1.SecretTransform, final string) bool {
-	for _, f := range cr.ObjectMeta.Finalizers {
+func hasFinalizer(cr transformObject, final string) bool {
+	for _, f := range cr.GetFinalizers() {
 		if f == final {
 			return true
 		}
@@ -239,135 +508,52 @@ func hasFinalizer(cr *ktransformv1alpha1.SecretTransform, final string) bool {
 	return false
 }
 
-type resource interface {
-	metav1.Object
-	runtime.Object
-}
-
+// transformedResource is a transformed output and the index of its status entry
 type transformedResource struct {
-	Resource resource
-	Apply    func()
+	*pipeline.Output
+	status int
 }
 
-func transformedResources(inputs func() map[string]interface{}, outputs []ktransformv1alpha1.Output) ([]*transformedResource, error) {
-	result := make([]*transformedResource, len(outputs))
-	for i, out := range outputs {
-		transformed, err := transformResource(inputs(), out)
-		if err != nil {
-			return nil, fmt.Errorf("output %d: %w", i, err)
-		}
-		result[i] = transformed
+func transformedResources(inputs func() map[string]interface{}, outputs []ktransformv1alpha1.Output, namespaces *pipeline.OutputNamespaces, observe func(time.Duration)) []*transformedResource {
+	transformed := pipeline.Transform(inputs, outputs, namespaces, observe)
+	result := make([]*transformedResource, len(transformed))
+	for i, o := range transformed {
+		result[i] = &transformedResource{Output: o}
 	}
-	return result, nil
+	return result
 }
 
-func transformResource(inputs map[string]interface{}, out ktransformv1alpha1.Output) (*transformedResource, error) {
-	if len(out.Transformation) == 0 {
-		return nil, errMissingTransformation
-	}
-	configMapName := ""
-	if out.ConfigMap != nil {
-		configMapName = out.ConfigMap.Name
-	}
-	secretName := ""
-	if out.Secret != nil {
-		secretName = out.Secret.Name
+func (r *ReconcileSecretTransform) inputLoader() *pipeline.InputLoader {
+	return &pipeline.InputLoader{
+		Client:            r.client,
+		RESTMapper:        r.restMapper,
+		AllowedNamespaces: r.options.AllowedInputNamespaces,
+		Watch:             r.watches.Watch,
 	}
-	if configMapName != "" && secretName != "" {
-		return nil, errAmbiguousResource
-	}
-	if configMapName == "" && secretName == "" {
-		return nil, errUnspecifiedResource
-	}
-	ctx := context.TODO()
-	transformed := map[string]interface{}{}
-	for k, query := range out.Transformation {
-		ctx, _ = context.WithTimeout(ctx, jqQueryTimeout)
-		v, err := transform.Query(ctx, inputs, query)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", k, err)
-		}
-		transformed[k] = v
-	}
-	if configMapName != "" {
-		m, err := transform.StringMapFromOutput(transformed)
-		if err != nil {
-			return nil, err
-		}
-		cm := &corev1.ConfigMap{}
-		cm.Name = configMapName
-		return &transformedResource{cm, func() { cm.Data = m }}, nil
-	}
-	m, err := transform.BytesMapFromOutput(transformed)
-	if err != nil {
-		return nil, err
+}
+
+func (r *ReconcileSecretTransform) outputNamespaces(namespace string) *pipeline.OutputNamespaces {
+	return &pipeline.OutputNamespaces{
+		Default: namespace,
+		Allowed: r.options.AllowedOutputNamespaces,
+		Client:  r.client,
 	}
-	sec := &corev1.Secret{}
-	sec.Name = secretName
-	return &transformedResource{sec, func() { sec.Data = m }}, nil
 }
 
-func (r *ReconcileSecretTransform) setSyncStatus(cr *ktransformv1alpha1.SecretTransform, s corev1.ConditionStatus, reason status.ConditionReason, msg string) error {
+// setSyncFailure emits a Warning event and sets the Synced condition to False
+func (r *ReconcileSecretTransform) setSyncFailure(cr transformObject, reason status.ConditionReason, err error) error {
+	r.recordFailure(cr, reason, err)
+	reconcileTotal.WithLabelValues(string(reason)).Inc()
 	syncCond := status.Condition{
 		Type:    ktransformv1alpha1.ConditionSynced,
-		Status:  s,
+		Status:  corev1.ConditionFalse,
 		Reason:  reason,
-		Message: msg,
+		Message: err.Error(),
 	}
-	if cr.Status.Conditions.SetCondition(syncCond) ||
-		cr.Status.ObservedGeneration != cr.Generation {
-		cr.Status.ObservedGeneration = cr.Generation
+	if cr.GetStatus().Conditions.SetCondition(syncCond) ||
+		cr.GetStatus().ObservedGeneration != cr.GetGeneration() {
+		cr.GetStatus().ObservedGeneration = cr.GetGeneration()
 		return r.client.Status().Update(context.TODO(), cr)
 	}
 	return nil
 }
-
-func (r *ReconcileSecretTransform) inputScopeFactory(namespace string, inputs map[string]ktransformv1alpha1.InputRef) (l []backrefs.Object, inputFactory func() map[string]interface{}, err error) {
-	constr := map[string]func() interface{}{}
-	for k, v := range inputs {
-		res, fn, err := r.loadInput(namespace, v)
-		if err != nil {
-			return nil, nil, fmt.Errorf("input %s: %w", k, err)
-		}
-		l = append(l, res)
-		constr[k] = fn
-	}
-	return l, func() map[string]interface{} {
-		scope := map[string]interface{}{}
-		for k, v := range constr {
-			scope[k] = v()
-		}
-		return scope
-	}, nil
-}
-
-func (r *ReconcileSecretTransform) loadInput(namespace string, input ktransformv1alpha1.InputRef) (backrefs.Object, func() interface{}, error) {
-	configMapName := ""
-	if input.ConfigMap != nil {
-		configMapName = *input.ConfigMap
-	}
-	secretName := ""
-	if input.Secret != nil {
-		secretName = *input.Secret
-	}
-	if configMapName != "" && secretName != "" {
-		return nil, nil, errAmbiguousResource
-	}
-	if configMapName == "" && secretName == "" {
-		return nil, nil, errUnspecifiedResource
-	}
-	if configMapName != "" {
-		key := types.NamespacedName{Name: configMapName, Namespace: namespace}
-		cm := &corev1.ConfigMap{}
-		err := r.client.Get(context.TODO(), key, cm)
-		return cm, func() interface{} {
-			return transform.InputMapFromStringMap(cm.Data)
-		}, err
-	}
-	key := types.NamespacedName{Name: secretName, Namespace: namespace}
-	sec := &corev1.Secret{}
-	err := r.client.Get(context.TODO(), key, sec)
-	return sec, func() interface{} {
-		return transform.InputMapFromBytesMap(sec.Data)
-	}, err
-}
@@ -0,0 +1,107 @@
+package secrettransform
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// indexFieldInputSelector indexes transforms by the kind and namespace of their input label selectors
+const indexFieldInputSelector = "spec.input.selector"
+
+// indexInputSelectors registers the index that maps the kind and namespace
+// of the objects a transform's input label selectors select to the transform
+func indexInputSelectors(indexer client.FieldIndexer, kind *transformKind) error {
+	return indexer.IndexField(context.TODO(), kind.New(), indexFieldInputSelector, func(o runtime.Object) []string {
+		return inputSelectorKeys(o.(transformObject))
+	})
+}
+
+func inputSelectorKeys(cr transformObject) (keys []string) {
+	seen := map[string]bool{}
+	for _, input := range cr.GetSpec().Input {
+		ns := input.Namespace
+		if ns == "" {
+			ns = cr.GetNamespace()
+		}
+		if input.SecretSelector != nil {
+			seen[inputSelectorKey("Secret", ns)] = true
+		}
+		if input.ConfigMapSelector != nil {
+			seen[inputSelectorKey("ConfigMap", ns)] = true
+		}
+	}
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	return
+}
+
+func inputSelectorKey(kind, namespace string) string {
+	return kind + "/" + namespace
+}
+
+// enqueueRequestsForSelectingTransforms enqueues a request for each transform of the given kind
+// with an input label selector that matches the changed Secret or ConfigMap.
+// Only the transforms with a selector for the object's kind and namespace are considered.
+// Objects that stop matching are handled by the back reference watch.
+func enqueueRequestsForSelectingTransforms(c client.Client, kind *transformKind) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) (r []reconcile.Request) {
+			var objKind string
+			switch o.Object.(type) {
+			case *corev1.Secret:
+				objKind = "Secret"
+			case *corev1.ConfigMap:
+				objKind = "ConfigMap"
+			default:
+				return nil
+			}
+			l, err := kind.List(c, client.MatchingFields{indexFieldInputSelector: inputSelectorKey(objKind, o.Meta.GetNamespace())})
+			if err != nil {
+				log.Error(err, "failed to list "+kind.Kind+"s")
+				return nil
+			}
+			for _, cr := range l {
+				if selectsObject(cr, o) {
+					r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}})
+				}
+			}
+			return
+		}),
+	}
+}
+
+func selectsObject(cr transformObject, o handler.MapObject) bool {
+	for _, input := range cr.GetSpec().Input {
+		var selector *metav1.LabelSelector
+		switch o.Object.(type) {
+		case *corev1.Secret:
+			selector = input.SecretSelector
+		case *corev1.ConfigMap:
+			selector = input.ConfigMapSelector
+		}
+		if selector == nil {
+			continue
+		}
+		ns := input.Namespace
+		if ns == "" {
+			ns = cr.GetNamespace()
+		}
+		if ns != o.Meta.GetNamespace() {
+			continue
+		}
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err == nil && sel.Matches(labels.Set(o.Meta.GetLabels())) {
+			return true
+		}
+	}
+	return false
+}
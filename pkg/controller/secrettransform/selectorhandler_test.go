@@ -0,0 +1,25 @@
+package secrettransform
+
+import (
+	"testing"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInputSelectorKeys(t *testing.T) {
+	name := "mysecret"
+	sel := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "x"}}
+	cr := &ktransformv1alpha1.SecretTransform{}
+	cr.Namespace = "myns"
+	cr.Spec.Input = map[string]ktransformv1alpha1.InputRef{
+		"sec":  {Secret: &name},
+		"sel":  {SecretSelector: sel},
+		"sel2": {SecretSelector: sel},
+		"conf": {ConfigMapSelector: sel, Namespace: "otherns"},
+	}
+	require.ElementsMatch(t, []string{"Secret/myns", "ConfigMap/otherns"}, inputSelectorKeys(cr))
+	cr.Spec.Input = map[string]ktransformv1alpha1.InputRef{"sec": {Secret: &name}}
+	require.Empty(t, inputSelectorKeys(cr), "transform without selectors")
+}
@@ -0,0 +1,71 @@
+package secrettransform
+
+import (
+	"context"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/backrefs"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+// transformKind provides the behaviour that differs between
+// the SecretTransform and ClusterSecretTransform controllers
+type transformKind struct {
+	Kind string
+	// ClusterScoped is true when the kind has no namespace
+	ClusterScoped bool
+	New           func() transformObject
+	List          func(c client.Client, opts ...client.ListOption) ([]transformObject, error)
+	// Validate returns spec errors that the pipeline does not detect (optional)
+	Validate func(spec *ktransformv1alpha1.SecretTransformSpec) field.ErrorList
+}
+
+var (
+	secretTransformKind = &transformKind{
+		Kind: "SecretTransform",
+		New:  func() transformObject { return &ktransformv1alpha1.SecretTransform{} },
+		List: func(c client.Client, opts ...client.ListOption) ([]transformObject, error) {
+			l := &ktransformv1alpha1.SecretTransformList{}
+			if err := c.List(context.TODO(), l, opts...); err != nil {
+				return nil, err
+			}
+			r := make([]transformObject, len(l.Items))
+			for i := range l.Items {
+				r[i] = &l.Items[i]
+			}
+			return r, nil
+		},
+	}
+	clusterSecretTransformKind = &transformKind{
+		Kind:          "ClusterSecretTransform",
+		ClusterScoped: true,
+		New:           func() transformObject { return &ktransformv1alpha1.ClusterSecretTransform{} },
+		List: func(c client.Client, opts ...client.ListOption) ([]transformObject, error) {
+			l := &ktransformv1alpha1.ClusterSecretTransformList{}
+			if err := c.List(context.TODO(), l, opts...); err != nil {
+				return nil, err
+			}
+			r := make([]transformObject, len(l.Items))
+			for i := range l.Items {
+				r[i] = &l.Items[i]
+			}
+			return r, nil
+		},
+		Validate: func(spec *ktransformv1alpha1.SecretTransformSpec) field.ErrorList {
+			return pipeline.ValidateClusterScopedSpec(spec, field.NewPath("spec"))
+		},
+	}
+)
+
+// enqueueRequestForInputReference returns an event handler that enqueues a request
+// for each transform that refers to the changed input using an annotation
+func (k *transformKind) enqueueRequestForInputReference() handler.EventHandler {
+	apiGroup := ktransformv1alpha1.SchemeGroupVersion.Group
+	if k.ClusterScoped {
+		return backrefs.EnqueueRequestForClusterAnnotationReference(k.Kind, apiGroup)
+	}
+	return backrefs.EnqueueRequestForAnnotationReference(k.Kind, apiGroup)
+}
@@ -0,0 +1,16 @@
+package secrettransform
+
+import (
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// transformObject is a SecretTransform or a ClusterSecretTransform.
+// A ClusterSecretTransform has no namespace.
+type transformObject interface {
+	runtime.Object
+	metav1.Object
+	GetSpec() *ktransformv1alpha1.SecretTransformSpec
+	GetStatus() *ktransformv1alpha1.SecretTransformStatus
+}
@@ -4,25 +4,37 @@ import (
 	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
 	"github.com/mgoltzsche/ktransform/pkg/backrefs"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type referenceOwner struct {
-	*ktransformv1alpha1.SecretTransform
+	transformObject
 }
 
 func (s *referenceOwner) GetStatusReferences() []backrefs.Object {
-	o := make([]backrefs.Object, 0, len(s.Status.ManagedReferences))
-	for _, ref := range s.Status.ManagedReferences {
-		switch ref.Kind {
-		case "Secret":
+	o := make([]backrefs.Object, 0, len(s.GetStatus().ManagedReferences))
+	for _, ref := range s.GetStatus().ManagedReferences {
+		ns := ref.Namespace
+		if ns == "" {
+			ns = s.GetNamespace()
+		}
+		switch {
+		case ref.APIVersion != "":
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion(ref.APIVersion)
+			obj.SetKind(ref.Kind)
+			obj.SetName(ref.Name)
+			obj.SetNamespace(ns)
+			o = append(o, obj)
+		case ref.Kind == "Secret":
 			sec := &corev1.Secret{}
 			sec.Name = ref.Name
-			sec.Namespace = s.Namespace
+			sec.Namespace = ns
 			o = append(o, sec)
-		case "ConfigMap":
+		case ref.Kind == "ConfigMap":
 			cm := &corev1.ConfigMap{}
 			cm.Name = ref.Name
-			cm.Namespace = s.Namespace
+			cm.Namespace = ns
 			o = append(o, cm)
 		}
 	}
@@ -36,10 +48,16 @@ func (s *referenceOwner) SetStatusReferences(refs []backrefs.Object) {
 			Kind: ref.GetObjectKind().GroupVersionKind().Kind,
 			Name: ref.GetName(),
 		}
+		if ns := ref.GetNamespace(); ns != s.GetNamespace() {
+			o[i].Namespace = ns
+		}
+		if _, ok := ref.(*unstructured.Unstructured); ok {
+			o[i].APIVersion = ref.GetObjectKind().GroupVersionKind().GroupVersion().String()
+		}
 	}
-	s.Status.ManagedReferences = o
+	s.GetStatus().ManagedReferences = o
 }
 
 func (owner *referenceOwner) GetObject() backrefs.Object {
-	return owner.SecretTransform
+	return owner.transformObject
 }
@@ -0,0 +1,355 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"sort"
+	"time"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultCADuration          = 10 * 365 * 24 * time.Hour
+	defaultCertificateDuration = 90 * 24 * time.Hour
+)
+
+var serialNumberLimit = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// generateCA returns the PEM encoded certificate and private key of a new self-signed CA
+func generateCA(name string, gen *ktransformv1alpha1.CAGenerator) ([]byte, error) {
+	key, err := generateKeyOrDefault(gen.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	cn := gen.CommonName
+	if cn == "" {
+		cn = name
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(durationOrDefault(gen.Duration, defaultCADuration)),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certPEM, err := createCertificate(tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := EncodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return append(certPEM, keyPEM...), nil
+}
+
+// caScope returns the CA certificate and private key PEM blocks as object
+func caScope(b []byte) (map[string]interface{}, error) {
+	var certPEM, keyPEM []byte
+	for rest := b; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			certPEM = pem.EncodeToMemory(block)
+		case "PRIVATE KEY":
+			keyPEM = pem.EncodeToMemory(block)
+		}
+	}
+	if certPEM == nil || keyPEM == nil {
+		return nil, errors.New("CA certificate or key missing")
+	}
+	return map[string]interface{}{"certificate": string(certPEM), "privateKey": string(keyPEM)}, nil
+}
+
+// certificateRequest is a certificate to be issued into a Secret
+type certificateRequest struct {
+	ca          *x509.Certificate
+	caKey       crypto.Signer
+	caPEM       []byte
+	commonName  string
+	dnsNames    []string
+	ipAddresses []net.IP
+	duration    time.Duration
+	renewBefore time.Duration
+	key         *ktransformv1alpha1.PrivateKeyGenerator
+}
+
+// transformCertificate evaluates the certificate spec's queries and resolves its issuer
+func transformCertificate(inputs map[string]interface{}, engine transform.Engine, spec *ktransformv1alpha1.CertificateOutput) (*certificateRequest, error) {
+	issuer, ok := inputs[spec.Issuer].(map[string]interface{})
+	if !ok || issuer["certificate"] == nil || issuer["privateKey"] == nil {
+		return nil, fmt.Errorf("%w: %q is not a ca generator", ErrInvalidIssuer, spec.Issuer)
+	}
+	caPEM := []byte(issuer["certificate"].(string))
+	ca, err := parseCertificate(caPEM)
+	if err != nil {
+		return nil, fmt.Errorf("issuer %s: %w", spec.Issuer, err)
+	}
+	caKey, err := DecodePrivateKey([]byte(issuer["privateKey"].(string)))
+	if err != nil {
+		return nil, fmt.Errorf("issuer %s: %w", spec.Issuer, err)
+	}
+	req := &certificateRequest{ca: ca, caKey: caKey, caPEM: caPEM, key: spec.PrivateKey}
+	if req.commonName, err = queryString(inputs, engine, "commonName", spec.CommonName); err != nil {
+		return nil, err
+	}
+	if req.dnsNames, err = queryStrings(inputs, engine, "dnsNames", spec.DNSNames); err != nil {
+		return nil, err
+	}
+	ips, err := queryStrings(inputs, engine, "ipAddresses", spec.IPAddresses)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("ipAddresses: %w", &transform.ResultError{Reason: "invalid IP address", Err: fmt.Errorf("invalid IP address %q", s)})
+		}
+		req.ipAddresses = append(req.ipAddresses, ip)
+	}
+	if req.commonName == "" && len(req.dnsNames) == 0 && len(req.ipAddresses) == 0 {
+		return nil, fmt.Errorf("%w: neither commonName, dnsNames nor ipAddresses resolved", ErrInvalidCertificate)
+	}
+	req.duration = durationOrDefault(spec.Duration, defaultCertificateDuration)
+	req.renewBefore = durationOrDefault(spec.RenewBefore, req.duration/3)
+	if req.renewBefore >= req.duration {
+		return nil, fmt.Errorf("%w: renewBefore must be less than duration", ErrInvalidCertificate)
+	}
+	return req, nil
+}
+
+// apply keeps the Secret's certificate if it is still valid and matches the request
+// or issues a new one. It returns the time the certificate must be renewed at
+// and a warning when the CA expires before the certificate can be renewed.
+func (r *certificateRequest) apply(sec *corev1.Secret, existing map[string][]byte) (time.Time, string, error) {
+	now := time.Now()
+	if cert := r.reusableCertificate(existing, now); cert != nil {
+		sec.Data[corev1.TLSCertKey] = existing[corev1.TLSCertKey]
+		sec.Data[corev1.TLSPrivateKeyKey] = existing[corev1.TLSPrivateKeyKey]
+		sec.Data[corev1.ServiceAccountRootCAKey] = r.caPEM
+		renewAt, warning := r.renewAt(cert.NotAfter, now)
+		return renewAt, warning, nil
+	}
+	if !now.Before(r.ca.NotAfter) {
+		return time.Time{}, "", fmt.Errorf("%w: CA expired at %s, rotate the CA", ErrInvalidIssuer, r.ca.NotAfter.Format(time.RFC3339))
+	}
+	key, err := generateKeyOrDefault(r.key)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: r.commonName},
+		DNSNames:    r.dnsNames,
+		IPAddresses: r.ipAddresses,
+		NotBefore:   now.Add(-5 * time.Minute),
+		NotAfter:    now.Add(r.duration),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if tmpl.NotAfter.After(r.ca.NotAfter) {
+		tmpl.NotAfter = r.ca.NotAfter
+	}
+	certPEM, err := createCertificate(tmpl, r.ca, key.Public(), r.caKey)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	keyPEM, err := EncodePrivateKey(key)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	sec.Data[corev1.TLSCertKey] = certPEM
+	sec.Data[corev1.TLSPrivateKeyKey] = keyPEM
+	sec.Data[corev1.ServiceAccountRootCAKey] = r.caPEM
+	renewAt, warning := r.renewAt(tmpl.NotAfter, now)
+	return renewAt, warning, nil
+}
+
+// renewAt returns the time a certificate that expires at notAfter must be renewed at.
+// When the CA expires within renewBefore a renewed certificate would not be valid any longer
+// since it cannot outlive the CA. In that case the certificate is kept until it expires
+// and a warning that asks to rotate the CA is returned.
+func (r *certificateRequest) renewAt(notAfter, now time.Time) (time.Time, string) {
+	if r.ca.NotAfter.After(now.Add(r.renewBefore)) {
+		return notAfter.Add(-r.renewBefore), ""
+	}
+	return notAfter, fmt.Sprintf("issuer CA expires at %s before the certificate can be renewed, rotate the CA", r.ca.NotAfter.Format(time.RFC3339))
+}
+
+// reusableCertificate returns the existing certificate if it has been issued by the
+// requested CA for the requested names, matches the key and is not due for renewal.
+func (r *certificateRequest) reusableCertificate(existing map[string][]byte, now time.Time) *x509.Certificate {
+	cert, err := parseCertificate(existing[corev1.TLSCertKey])
+	if err != nil {
+		return nil
+	}
+	key, err := DecodePrivateKey(existing[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil
+	}
+	if cert.CheckSignatureFrom(r.ca) != nil ||
+		!publicKeyEqual(cert.PublicKey, key.Public()) ||
+		!keyMatches(r.key, key) ||
+		cert.Subject.CommonName != r.commonName ||
+		!stringSetEqual(cert.DNSNames, r.dnsNames) ||
+		!stringSetEqual(ipStrings(cert.IPAddresses), ipStrings(r.ipAddresses)) ||
+		cert.NotAfter.Sub(cert.NotBefore) > r.duration+5*time.Minute {
+		return nil
+	}
+	if renewAt, _ := r.renewAt(cert.NotAfter, now); !now.Before(renewAt) {
+		return nil
+	}
+	return cert
+}
+
+func createCertificate(tmpl, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.SerialNumber = serial
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, signer)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func parseCertificate(b []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func generateKeyOrDefault(gen *ktransformv1alpha1.PrivateKeyGenerator) (crypto.Signer, error) {
+	if gen == nil {
+		return GenerateKey(ktransformv1alpha1.KeyTypeECDSA, 0)
+	}
+	return GenerateKey(gen.Type, gen.Bits)
+}
+
+// keyMatches returns true if the key has the type and size specified by the generator
+func keyMatches(gen *ktransformv1alpha1.PrivateKeyGenerator, key crypto.Signer) bool {
+	keyType, bits := ktransformv1alpha1.KeyTypeECDSA, 0
+	if gen != nil {
+		keyType, bits = gen.Type, gen.Bits
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if bits == 0 {
+			bits = 2048
+		}
+		return keyType == ktransformv1alpha1.KeyTypeRSA && k.N.BitLen() == bits
+	case *ecdsa.PrivateKey:
+		if bits == 0 {
+			bits = 256
+		}
+		return keyType == ktransformv1alpha1.KeyTypeECDSA && k.Curve.Params().BitSize == bits
+	case ed25519.PrivateKey:
+		return keyType == ktransformv1alpha1.KeyTypeEd25519
+	}
+	return false
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	da, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	db, err := x509.MarshalPKIXPublicKey(b)
+	return err == nil && bytes.Equal(da, db)
+}
+
+func durationOrDefault(d *metav1.Duration, def time.Duration) time.Duration {
+	if d == nil || d.Duration <= 0 {
+		return def
+	}
+	return d.Duration
+}
+
+func queryString(inputs map[string]interface{}, engine transform.Engine, name, query string) (string, error) {
+	if query == "" {
+		return "", nil
+	}
+	l, err := queryStrings(inputs, engine, name, query)
+	if err != nil {
+		return "", err
+	}
+	if len(l) > 1 {
+		return "", fmt.Errorf("%s: query returned a list", name)
+	}
+	if len(l) == 0 {
+		return "", nil
+	}
+	return l[0], nil
+}
+
+// queryStrings evaluates a query that returns a string, a list of strings or null
+func queryStrings(inputs map[string]interface{}, engine transform.Engine, name, query string) ([]string, error) {
+	if query == "" {
+		return nil, nil
+	}
+	v, err := transform.Evaluate(engine, inputs, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		l := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: query returned non-string list item %T", name, item)
+			}
+			l = append(l, s)
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("%s: query returned %T instead of string or list", name, v)
+}
+
+func ipStrings(ips []net.IP) []string {
+	l := make([]string, len(ips))
+	for i, ip := range ips {
+		l[i] = ip.String()
+	}
+	return l
+}
+
+func stringSetEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
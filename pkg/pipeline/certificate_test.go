@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	goerrors "errors"
+	"testing"
+	"time"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCertificate(t *testing.T) {
+	generators := map[string]ktransformv1alpha1.Generator{"ca": {CA: &ktransformv1alpha1.CAGenerator{}}}
+	state := &corev1.Secret{}
+	_, err := Generate(nil, generators, state)
+	require.NoError(t, err)
+	host := "svc.example.org"
+	scope, err := GeneratedScope(func() map[string]interface{} {
+		return map[string]interface{}{"host": host}
+	}, generators, state)
+	require.NoError(t, err)
+	outputs := []ktransformv1alpha1.Output{{Secret: &ktransformv1alpha1.SecretOutput{
+		Name: "tls",
+		Certificate: &ktransformv1alpha1.CertificateOutput{
+			Issuer:   "ca",
+			DNSNames: `[.host, "localhost"]`,
+			Duration: &metav1.Duration{Duration: 3 * time.Hour},
+		},
+	}}}
+	transformSecret := func(existing *corev1.Secret) *Output {
+		o := Transform(scope, outputs, nil, nil)[0]
+		require.NoError(t, o.Err)
+		existing.DeepCopyInto(o.Resource.(*corev1.Secret))
+		require.NoError(t, o.Apply())
+		return o
+	}
+
+	o := transformSecret(&corev1.Secret{})
+	sec := o.Resource.(*corev1.Secret)
+	require.Equal(t, corev1.SecretTypeTLS, sec.Type, "type")
+	cert, err := parseCertificate(sec.Data[corev1.TLSCertKey])
+	require.NoError(t, err)
+	require.Equal(t, []string{host, "localhost"}, cert.DNSNames, "dnsNames")
+	ca, err := parseCertificate(sec.Data[corev1.ServiceAccountRootCAKey])
+	require.NoError(t, err)
+	require.NoError(t, cert.CheckSignatureFrom(ca), "certificate should be signed by CA")
+	_, err = DecodePrivateKey(sec.Data[corev1.TLSPrivateKeyKey])
+	require.NoError(t, err, "tls.key")
+	require.WithinDuration(t, cert.NotAfter.Add(-time.Hour), o.RenewAt, time.Second, "renewAt")
+
+	o = transformSecret(sec.DeepCopy())
+	require.Equal(t, sec.Data, o.Resource.(*corev1.Secret).Data, "should reuse valid certificate")
+
+	host = "other.example.org"
+	o = transformSecret(sec.DeepCopy())
+	require.NotEqual(t, sec.Data[corev1.TLSCertKey], o.Resource.(*corev1.Secret).Data[corev1.TLSCertKey], "should reissue certificate when SANs change")
+}
+
+func TestCertificateExpiringCA(t *testing.T) {
+	generators := map[string]ktransformv1alpha1.Generator{"ca": {CA: &ktransformv1alpha1.CAGenerator{
+		Duration: &metav1.Duration{Duration: 30 * time.Minute},
+	}}}
+	state := &corev1.Secret{}
+	_, err := Generate(nil, generators, state)
+	require.NoError(t, err)
+	scope, err := GeneratedScope(func() map[string]interface{} { return map[string]interface{}{} }, generators, state)
+	require.NoError(t, err)
+	outputs := []ktransformv1alpha1.Output{{Secret: &ktransformv1alpha1.SecretOutput{
+		Name: "tls",
+		Certificate: &ktransformv1alpha1.CertificateOutput{
+			Issuer:      "ca",
+			CommonName:  `"svc"`,
+			Duration:    &metav1.Duration{Duration: 3 * time.Hour},
+			RenewBefore: &metav1.Duration{Duration: time.Hour},
+		},
+	}}}
+	transformSecret := func(existing *corev1.Secret) *Output {
+		o := Transform(scope, outputs, nil, nil)[0]
+		require.NoError(t, o.Err)
+		existing.DeepCopyInto(o.Resource.(*corev1.Secret))
+		require.NoError(t, o.Apply())
+		return o
+	}
+
+	o := transformSecret(&corev1.Secret{})
+	sec := o.Resource.(*corev1.Secret)
+	cert, err := parseCertificate(sec.Data[corev1.TLSCertKey])
+	require.NoError(t, err)
+	ca, err := parseCertificate(sec.Data[corev1.ServiceAccountRootCAKey])
+	require.NoError(t, err)
+	require.Equal(t, ca.NotAfter, cert.NotAfter, "certificate should not outlive the CA")
+	require.Equal(t, cert.NotAfter, o.RenewAt, "should keep the certificate until it expires")
+	require.Contains(t, o.Warning, "rotate the CA", "warning")
+
+	o = transformSecret(sec.DeepCopy())
+	require.Equal(t, sec.Data, o.Resource.(*corev1.Secret).Data, "should not reissue the certificate")
+	require.True(t, o.RenewAt.After(time.Now()), "renewAt should not be in the past")
+}
+
+func TestCertificateExpiredCA(t *testing.T) {
+	key, err := GenerateKey(ktransformv1alpha1.KeyTypeECDSA, 0)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-2 * time.Hour),
+		NotAfter:              time.Now().Add(-time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caPEM, err := createCertificate(tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	ca, err := parseCertificate(caPEM)
+	require.NoError(t, err)
+	r := &certificateRequest{ca: ca, caKey: key, caPEM: caPEM, commonName: "svc", duration: 3 * time.Hour, renewBefore: time.Hour}
+	_, _, err = r.apply(&corev1.Secret{Data: map[string][]byte{}}, nil)
+	require.True(t, goerrors.Is(err, ErrInvalidIssuer), "should fail with expired CA but returned %v", err)
+}
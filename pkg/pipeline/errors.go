@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"errors"
+
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+)
+
+var (
+	ErrInvalidSpec               = errors.New("invalid spec")
+	ErrInvalidObject             = errors.New("invalid object")
+	ErrNamespaceNotAllowed       = errors.New("input namespace not allowed")
+	ErrInvalidSelector           = errors.New("invalid selector")
+	ErrUnknownKind               = errors.New("unknown kind")
+	ErrClusterScopedKind         = errors.New("cluster-scoped kinds are not supported")
+	ErrInvalidGenerator          = errors.New("invalid generator")
+	ErrInvalidIssuer             = errors.New("invalid issuer")
+	ErrInvalidCertificate        = errors.New("invalid certificate")
+	ErrUnknownFormat             = errors.New("unknown format")
+	ErrOutputNamespaceNotAllowed = errors.New("output namespace not allowed")
+	ErrMissingNamespace          = errors.New("no namespace specified")
+)
+
+// IsSpecError returns true if the error is caused by an invalid SecretTransform spec
+func IsSpecError(err error) bool {
+	return errors.Is(err, ErrInvalidSpec) ||
+		errors.Is(err, ErrInvalidObject) ||
+		errors.Is(err, ErrNamespaceNotAllowed) ||
+		errors.Is(err, ErrInvalidSelector) ||
+		errors.Is(err, ErrUnknownKind) ||
+		errors.Is(err, ErrClusterScopedKind) ||
+		errors.Is(err, ErrInvalidGenerator) ||
+		errors.Is(err, ErrInvalidIssuer) ||
+		errors.Is(err, ErrInvalidCertificate) ||
+		errors.Is(err, ErrUnknownFormat) ||
+		errors.Is(err, ErrOutputNamespaceNotAllowed) ||
+		errors.Is(err, ErrMissingNamespace) ||
+		errors.Is(err, transform.ErrInvalidQuery) ||
+		errors.Is(err, transform.ErrUnknownEngine)
+}
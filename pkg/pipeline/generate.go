@@ -0,0 +1,289 @@
+package pipeline
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/google/uuid"
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// AnnotationGenerators stores the fingerprints of the generators
+	// the values within a state Secret have been generated with
+	AnnotationGenerators = "ktransform.mgoltzsche.github.com/generators"
+
+	defaultRandomStringLength  = 32
+	maxRandomStringLength      = 4096
+	defaultRandomStringCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+var (
+	errCorruptedState     = errors.New("corrupted state")
+	errUnsupportedKeySize = errors.New("unsupported key size")
+)
+
+// StateSecretName returns the name of the Secret the generated values of a SecretTransform are stored in
+func StateSecretName(transformName string) string {
+	return transformName + "-ktransform-state"
+}
+
+// Generate generates the values that are not contained in the state Secret
+// or whose rotation or generator kind changed and removes the values it generated previously
+// that are not specified anymore. Keys that have not been generated are left untouched.
+// It returns the names of the (re)generated values.
+// A generator must not have the same name as an input.
+func Generate(inputs map[string]ktransformv1alpha1.InputRef, generators map[string]ktransformv1alpha1.Generator, state *corev1.Secret) (generated []string, err error) {
+	var errs field.ErrorList
+	for _, name := range sortedGeneratorNames(generators) {
+		errs = append(errs, validateGenerator(name, generators[name], inputs, field.NewPath("spec", "generate").Key(name))...)
+	}
+	if err = specError(errs); err != nil {
+		return nil, err
+	}
+	fingerprints := map[string]string{}
+	if a := state.Annotations[AnnotationGenerators]; a != "" {
+		if err = json.Unmarshal([]byte(a), &fingerprints); err != nil {
+			return nil, fmt.Errorf("%w: %s", errCorruptedState, err)
+		}
+	}
+	if state.Data == nil {
+		state.Data = map[string][]byte{}
+	}
+	changed := false
+	for name := range fingerprints {
+		if _, ok := generators[name]; !ok {
+			delete(state.Data, name)
+			delete(fingerprints, name)
+			changed = true
+		}
+	}
+	for _, name := range sortedGeneratorNames(generators) {
+		gen := generators[name]
+		fingerprint, err := generatorFingerprint(gen)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := state.Data[name]; ok && fingerprints[name] == fingerprint {
+			continue
+		}
+		v, err := generateValue(name, gen)
+		if err != nil {
+			return nil, fmt.Errorf("generate %s: %w", name, err)
+		}
+		state.Data[name] = v
+		fingerprints[name] = fingerprint
+		generated = append(generated, name)
+		changed = true
+	}
+	if changed {
+		b, err := json.Marshal(fingerprints)
+		if err != nil {
+			return nil, err
+		}
+		if state.Annotations == nil {
+			state.Annotations = map[string]string{}
+		}
+		state.Annotations[AnnotationGenerators] = string(b)
+	}
+	return generated, nil
+}
+
+// GeneratedScope returns a function that adds the generated values to the input scope.
+// Private keys are exposed as object with privateKey and publicKey,
+// CAs as object with certificate and privateKey, other values as string.
+func GeneratedScope(inputs func() map[string]interface{}, generators map[string]ktransformv1alpha1.Generator, state *corev1.Secret) (func() map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for name, gen := range generators {
+		v, ok := state.Data[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: value %s has not been generated", errCorruptedState, name)
+		}
+		if gen.PrivateKey != nil {
+			pub, err := publicKeyPEM(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", errCorruptedState, name, err)
+			}
+			values[name] = map[string]interface{}{"privateKey": string(v), "publicKey": pub}
+			continue
+		}
+		if gen.CA != nil {
+			ca, err := caScope(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", errCorruptedState, name, err)
+			}
+			values[name] = ca
+			continue
+		}
+		values[name] = string(v)
+	}
+	return func() map[string]interface{} {
+		scope := inputs()
+		for k, v := range values {
+			scope[k] = v
+		}
+		return scope
+	}, nil
+}
+
+// generatorFingerprint identifies the kind of value a generator produces and its rotation.
+// Other changes to the generator do not cause the value to be regenerated.
+func generatorFingerprint(gen ktransformv1alpha1.Generator) (string, error) {
+	kind := ""
+	switch {
+	case gen.RandomString != nil:
+		kind = "randomString"
+	case gen.PrivateKey != nil:
+		kind = "privateKey"
+	case gen.UUID != nil:
+		kind = "uuid"
+	case gen.CA != nil:
+		kind = "ca"
+	}
+	b, err := json.Marshal([]string{kind, gen.Rotation})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b))[:16], nil
+}
+
+func generateValue(name string, gen ktransformv1alpha1.Generator) ([]byte, error) {
+	switch {
+	case gen.RandomString != nil:
+		return randomString(gen.RandomString)
+	case gen.PrivateKey != nil:
+		return privateKey(gen.PrivateKey)
+	case gen.CA != nil:
+		return generateCA(name, gen.CA)
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(id.String()), nil
+}
+
+func randomString(gen *ktransformv1alpha1.RandomStringGenerator) ([]byte, error) {
+	length := gen.Length
+	if length == 0 {
+		length = defaultRandomStringLength
+	}
+	charset := []rune(gen.Charset)
+	if len(charset) == 0 {
+		charset = []rune(defaultRandomStringCharset)
+	}
+	max := big.NewInt(int64(len(charset)))
+	s := make([]rune, length)
+	for i := range s {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		s[i] = charset[n.Int64()]
+	}
+	return []byte(string(s)), nil
+}
+
+func privateKey(gen *ktransformv1alpha1.PrivateKeyGenerator) ([]byte, error) {
+	key, err := GenerateKey(gen.Type, gen.Bits)
+	if err != nil {
+		return nil, err
+	}
+	return EncodePrivateKey(key)
+}
+
+// GenerateKey generates a private key of the given type and size.
+// A size of 0 selects the type's default size.
+func GenerateKey(keyType ktransformv1alpha1.KeyType, bits int) (crypto.Signer, error) {
+	switch keyType {
+	case ktransformv1alpha1.KeyTypeRSA:
+		if bits == 0 {
+			bits = 2048
+		}
+		if bits != 2048 && bits != 3072 && bits != 4096 {
+			return nil, fmt.Errorf("%w: %s: RSA %d", ErrInvalidGenerator, errUnsupportedKeySize, bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case ktransformv1alpha1.KeyTypeECDSA:
+		var curve elliptic.Curve
+		switch bits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: %s: ECDSA %d", ErrInvalidGenerator, errUnsupportedKeySize, bits)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case ktransformv1alpha1.KeyTypeEd25519:
+		if bits != 0 {
+			return nil, fmt.Errorf("%w: %s: Ed25519 %d", ErrInvalidGenerator, errUnsupportedKeySize, bits)
+		}
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("%w: unsupported key type %q", ErrInvalidGenerator, keyType)
+}
+
+// EncodePrivateKey returns the PEM encoded PKCS#8 representation of the given key
+func EncodePrivateKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// DecodePrivateKey parses a PEM encoded PKCS#8 private key
+func DecodePrivateKey(b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+func publicKeyPEM(privateKeyPEM []byte) (string, error) {
+	key, err := DecodePrivateKey(privateKeyPEM)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func sortedGeneratorNames(generators map[string]ktransformv1alpha1.Generator) []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
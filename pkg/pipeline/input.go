@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/backrefs"
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// InputLoader reads the inputs of a SecretTransform
+type InputLoader struct {
+	Client client.Reader
+	// RESTMapper is used to reject cluster-scoped input kinds (optional)
+	RESTMapper meta.RESTMapper
+	// AllowedNamespaces lists the namespaces inputs may be read from
+	// in addition to the SecretTransform's namespace. "*" allows any namespace.
+	AllowedNamespaces []string
+	// Watch is called before an object of an arbitrary kind is read (optional)
+	Watch func(gvk schema.GroupVersionKind) error
+}
+
+func (l *InputLoader) isNamespaceAllowed(namespace string) bool {
+	for _, ns := range l.AllowedNamespaces {
+		if ns == namespace || ns == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads the given inputs and returns the objects that have been read
+// as well as a function that creates the transformation's input scope.
+func (l *InputLoader) Load(namespace string, inputs map[string]ktransformv1alpha1.InputRef) (refs []backrefs.Object, inputFactory func() map[string]interface{}, err error) {
+	constr := map[string]func() interface{}{}
+	for k, v := range inputs {
+		if err := specError(validateInput(v, field.NewPath("spec", "input").Key(k))); err != nil {
+			return nil, nil, err
+		}
+		res, fn, err := l.loadInput(namespace, v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("input %s: %w", k, err)
+		}
+		refs = append(refs, res...)
+		constr[k] = fn
+	}
+	return refs, func() map[string]interface{} {
+		scope := map[string]interface{}{}
+		for k, v := range constr {
+			scope[k] = v()
+		}
+		return scope
+	}, nil
+}
+
+func (l *InputLoader) loadInput(namespace string, input ktransformv1alpha1.InputRef) ([]backrefs.Object, func() interface{}, error) {
+	configMapName := ""
+	if input.ConfigMap != nil {
+		configMapName = *input.ConfigMap
+	}
+	secretName := ""
+	if input.Secret != nil {
+		secretName = *input.Secret
+	}
+	isObjectRef := input.APIVersion != "" || input.Kind != "" || input.Name != ""
+	if input.Namespace != "" && input.Namespace != namespace {
+		if !l.isNamespaceAllowed(input.Namespace) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNamespaceNotAllowed, input.Namespace)
+		}
+		namespace = input.Namespace
+	}
+	if namespace == "" {
+		return nil, nil, fmt.Errorf("%w for input", ErrMissingNamespace)
+	}
+	formats := make(map[string]string, len(input.Formats))
+	for k, f := range input.Formats {
+		if !transform.IsFormat(string(f)) {
+			return nil, nil, fmt.Errorf("%w %q for key %s", ErrUnknownFormat, f, k)
+		}
+		formats[k] = string(f)
+	}
+	switch {
+	case isObjectRef:
+		return l.loadObject(namespace, input)
+	case input.SecretSelector != nil:
+		return l.loadSecrets(namespace, input.SecretSelector, formats)
+	case input.ConfigMapSelector != nil:
+		return l.loadConfigMaps(namespace, input.ConfigMapSelector, formats)
+	case configMapName != "":
+		key := types.NamespacedName{Name: configMapName, Namespace: namespace}
+		cm := &corev1.ConfigMap{}
+		err := l.Client.Get(context.TODO(), key, cm)
+		return []backrefs.Object{cm}, func() interface{} {
+			return transform.InputMapFromStringMap(cm.Data, formats)
+		}, err
+	}
+	key := types.NamespacedName{Name: secretName, Namespace: namespace}
+	sec := &corev1.Secret{}
+	err := l.Client.Get(context.TODO(), key, sec)
+	return []backrefs.Object{sec}, func() interface{} {
+		return transform.InputMapFromBytesMap(sec.Data, formats)
+	}, err
+}
+
+func (l *InputLoader) loadSecrets(namespace string, selector *metav1.LabelSelector, formats map[string]string) ([]backrefs.Object, func() interface{}, error) {
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidSelector, err)
+	}
+	list := &corev1.SecretList{}
+	err = l.Client.List(context.TODO(), list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: sel})
+	if err != nil {
+		return nil, nil, err
+	}
+	refs := make([]backrefs.Object, len(list.Items))
+	for i := range list.Items {
+		refs[i] = &list.Items[i]
+	}
+	return refs, func() interface{} {
+		m := map[string]interface{}{}
+		for _, sec := range list.Items {
+			m[sec.Name] = transform.InputMapFromBytesMap(sec.Data, formats)
+		}
+		return m
+	}, nil
+}
+
+func (l *InputLoader) loadConfigMaps(namespace string, selector *metav1.LabelSelector, formats map[string]string) ([]backrefs.Object, func() interface{}, error) {
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidSelector, err)
+	}
+	list := &corev1.ConfigMapList{}
+	err = l.Client.List(context.TODO(), list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: sel})
+	if err != nil {
+		return nil, nil, err
+	}
+	refs := make([]backrefs.Object, len(list.Items))
+	for i := range list.Items {
+		refs[i] = &list.Items[i]
+	}
+	return refs, func() interface{} {
+		m := map[string]interface{}{}
+		for _, cm := range list.Items {
+			m[cm.Name] = transform.InputMapFromStringMap(cm.Data, formats)
+		}
+		return m
+	}, nil
+}
+
+func (l *InputLoader) loadObject(namespace string, input ktransformv1alpha1.InputRef) ([]backrefs.Object, func() interface{}, error) {
+	gvk := schema.FromAPIVersionAndKind(input.APIVersion, input.Kind)
+	if l.RESTMapper != nil {
+		mapping, err := l.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				return nil, nil, fmt.Errorf("%w: %s", ErrUnknownKind, err)
+			}
+			return nil, nil, err
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+			return nil, nil, fmt.Errorf("%w: %s", ErrClusterScopedKind, input.Kind)
+		}
+	}
+	if l.Watch != nil {
+		if err := l.Watch(gvk); err != nil {
+			return nil, nil, err
+		}
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	key := types.NamespacedName{Name: input.Name, Namespace: namespace}
+	err := l.Client.Get(context.TODO(), key, obj)
+	return []backrefs.Object{obj}, func() interface{} {
+		return transform.InputFromObject(obj.Object)
+	}, err
+}
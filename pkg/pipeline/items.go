@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	"sigs.k8s.io/yaml"
+)
+
+// item is an element of the list returned by an output's items query
+type item struct {
+	Name        string                 `json:"name"`
+	Data        map[string]interface{} `json:"data"`
+	Labels      map[string]string      `json:"labels"`
+	Annotations map[string]string      `json:"annotations"`
+}
+
+// transformItems evaluates the output's items query and returns one Secret or ConfigMap per item
+func transformItems(inputs map[string]interface{}, out ktransformv1alpha1.Output, observe func(time.Duration)) ([]*Output, error) {
+	engine, hashes, err := outputEngine(out, observe)
+	if err != nil {
+		return nil, err
+	}
+	formats, err := outputFormats(out.Formats)
+	if err != nil {
+		return nil, err
+	}
+	items, err := queryItems(inputs, engine, out.Items)
+	if err != nil {
+		return nil, err
+	}
+	var spec ktransformv1alpha1.OutputMetadata
+	if out.Secret != nil {
+		spec = out.Secret.OutputMetadata
+	} else {
+		spec = out.ConfigMap.OutputMetadata
+	}
+	metadata, err := transformMetadata(inputs, engine, spec)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]struct{}{}
+	result := make([]*Output, 0, len(items))
+	for i, it := range items {
+		if it.Name == "" {
+			return nil, fmt.Errorf("items: item %d: no name specified", i)
+		}
+		if _, ok := names[it.Name]; ok {
+			return nil, fmt.Errorf("items: duplicate name %q", it.Name)
+		}
+		names[it.Name] = struct{}{}
+		itemMetadata := outputMetadata{
+			Labels:      mergeMaps(mergeMaps(nil, metadata.Labels), it.Labels),
+			Annotations: mergeMaps(mergeMaps(nil, metadata.Annotations), it.Annotations),
+		}
+		if out.ConfigMap != nil {
+			m, err := transform.StringMapFromOutput(it.Data, formats)
+			if err != nil {
+				return nil, fmt.Errorf("items: %s: %w", it.Name, err)
+			}
+			result = append(result, newConfigMapOutput(it.Name, m, itemMetadata, hashes))
+			continue
+		}
+		m, err := transform.BytesMapFromOutput(it.Data, formats)
+		if err != nil {
+			return nil, fmt.Errorf("items: %s: %w", it.Name, err)
+		}
+		result = append(result, newSecretOutput(it.Name, out.Secret.Type, m, itemMetadata, nil, hashes))
+	}
+	return result, nil
+}
+
+// queryItems evaluates a query that returns a list of items (or null)
+func queryItems(inputs map[string]interface{}, engine transform.Engine, query string) ([]item, error) {
+	v, err := transform.Evaluate(engine, inputs, query)
+	if err != nil {
+		return nil, fmt.Errorf("items: %w", err)
+	}
+	var b []byte
+	switch c := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		b = []byte(c)
+	case []interface{}:
+		if b, err = yaml.Marshal(c); err != nil {
+			return nil, fmt.Errorf("items: %w", &transform.ResultError{Reason: "cannot encode query result", Err: err})
+		}
+	default:
+		return nil, fmt.Errorf("items: query returned %T but list expected", v)
+	}
+	var items []item
+	if err = yaml.UnmarshalStrict(b, &items); err != nil {
+		return nil, fmt.Errorf("items: %w", &transform.ResultError{Reason: "cannot decode query result", Err: err})
+	}
+	return items, nil
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// MergePatch returns a server-side apply patch that contains only the fields
+// written by Apply: data, managed labels and annotations and,
+// when the Secret is created, its type.
+// Foreign keys are not contained in order to leave their ownership untouched.
+func MergePatch(o Resource, gvk schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	patch := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	switch c := o.(type) {
+	case *corev1.Secret:
+		if len(c.Data) > 0 {
+			obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&corev1.Secret{Data: c.Data})
+			if err != nil {
+				return nil, err
+			}
+			patch.Object["data"] = obj["data"]
+		}
+		if c.CreationTimestamp.IsZero() && c.Type != "" {
+			patch.Object["type"] = string(c.Type)
+		}
+	case *corev1.ConfigMap:
+		if len(c.Data) > 0 {
+			data := make(map[string]interface{}, len(c.Data))
+			for k, v := range c.Data {
+				data[k] = v
+			}
+			patch.Object["data"] = data
+		}
+	}
+	patch.SetGroupVersionKind(gvk)
+	patch.SetName(o.GetName())
+	patch.SetNamespace(o.GetNamespace())
+	annotations := o.GetAnnotations()
+	patch.SetLabels(managedEntries(o.GetLabels(), annotations[annotationManagedLabels]))
+	patch.SetAnnotations(managedEntries(annotations, annotations[annotationManagedAnnotations]))
+	return patch, nil
+}
+
+// ReleasePatch returns a server-side apply patch that contains no fields
+// in order to remove the fields that are owned exclusively by the field manager.
+func ReleasePatch(gvk schema.GroupVersionKind, namespace, name string) *unstructured.Unstructured {
+	patch := &unstructured.Unstructured{}
+	patch.SetGroupVersionKind(gvk)
+	patch.SetName(name)
+	patch.SetNamespace(namespace)
+	return patch
+}
+
+func managedEntries(m map[string]string, managed string) map[string]string {
+	if managed == "" {
+		return nil
+	}
+	r := map[string]string{}
+	for _, k := range strings.Split(managed, ",") {
+		if v, ok := m[k]; ok {
+			r[k] = v
+		}
+	}
+	return r
+}
@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"testing"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergePatch(t *testing.T) {
+	scope := func() map[string]interface{} { return map[string]interface{}{} }
+	outputs := Transform(scope, []ktransformv1alpha1.Output{{
+		Secret: &ktransformv1alpha1.SecretOutput{Name: "helm-secret", OutputMetadata: ktransformv1alpha1.OutputMetadata{
+			Labels: map[string]string{"app": "x"},
+		}},
+		Transformation: map[string]string{"password": `"generated"`},
+		WriteMode:      ktransformv1alpha1.WriteModeMerge,
+	}}, nil, nil)
+	require.Equal(t, 1, len(outputs), "outputs")
+	o := outputs[0]
+	require.NoError(t, o.Err)
+	require.Equal(t, ktransformv1alpha1.WriteModeMerge, o.WriteMode, "writeMode")
+	sec := o.Resource.(*corev1.Secret)
+	sec.Namespace = "myns"
+	sec.CreationTimestamp = metav1.Now()
+	sec.Type = corev1.SecretTypeOpaque
+	sec.Labels = map[string]string{"heritage": "Helm"}
+	sec.Annotations = map[string]string{"meta.helm.sh/release-name": "myrelease"}
+	sec.Data = map[string][]byte{"foreign": []byte("value")}
+	require.NoError(t, o.Apply())
+	patch, err := MergePatch(sec, corev1.SchemeGroupVersion.WithKind("Secret"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      "helm-secret",
+			"namespace": "myns",
+			"labels":    map[string]interface{}{"app": "x"},
+		},
+		"data": map[string]interface{}{"password": "Z2VuZXJhdGVk"},
+	}, patch.Object)
+}
+
+func TestTransformMergeObject(t *testing.T) {
+	scope := func() map[string]interface{} { return map[string]interface{}{} }
+	outputs := Transform(scope, []ktransformv1alpha1.Output{{
+		Object:    `{apiVersion: "v1", kind: "Service", metadata: {name: "a"}}`,
+		WriteMode: ktransformv1alpha1.WriteModeMerge,
+	}}, nil, nil)
+	require.Error(t, outputs[0].Err)
+	require.Equal(t, ktransformv1alpha1.ReasonInvalidSpec, outputs[0].Reason)
+}
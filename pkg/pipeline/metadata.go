@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	annotationManagedLabels      = "ktransform.mgoltzsche.github.com/managed-labels"
+	annotationManagedAnnotations = "ktransform.mgoltzsche.github.com/managed-annotations"
+)
+
+type outputMetadata struct {
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+func transformMetadata(inputs map[string]interface{}, engine transform.Engine, spec ktransformv1alpha1.OutputMetadata) (m outputMetadata, err error) {
+	m.Labels, err = metadataMap(inputs, engine, spec.Labels, spec.LabelTransformation)
+	if err != nil {
+		return m, fmt.Errorf("label %w", err)
+	}
+	m.Annotations, err = metadataMap(inputs, engine, spec.Annotations, spec.AnnotationTransformation)
+	if err != nil {
+		return m, fmt.Errorf("annotation %w", err)
+	}
+	return
+}
+
+func metadataMap(inputs map[string]interface{}, engine transform.Engine, static, queries map[string]string) (map[string]string, error) {
+	transformed, err := queryMap(inputs, engine, queries)
+	if err != nil {
+		return nil, err
+	}
+	m, err := transform.StringMapFromOutput(transformed, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range static {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return m, nil
+}
+
+// Apply sets the desired labels and annotations on the given object
+// while preserving the ones written by other actors.
+// The keys written by the controller are stored within annotations
+// in order to remove them when they are not desired anymore.
+func (m *outputMetadata) Apply(o metav1.Object) {
+	o.SetLabels(applyManagedEntries(o.GetLabels(), m.Labels, o.GetAnnotations()[annotationManagedLabels]))
+	a := applyManagedEntries(o.GetAnnotations(), m.Annotations, o.GetAnnotations()[annotationManagedAnnotations])
+	for k, entries := range map[string]map[string]string{annotationManagedLabels: m.Labels, annotationManagedAnnotations: m.Annotations} {
+		if len(entries) == 0 {
+			delete(a, k)
+			continue
+		}
+		if a == nil {
+			a = map[string]string{}
+		}
+		a[k] = strings.Join(sortedKeys(entries), ",")
+	}
+	o.SetAnnotations(a)
+}
+
+func applyManagedEntries(m, desired map[string]string, lastManaged string) map[string]string {
+	if lastManaged != "" {
+		for _, k := range strings.Split(lastManaged, ",") {
+			if _, ok := desired[k]; !ok {
+				delete(m, k)
+			}
+		}
+	}
+	if len(desired) == 0 {
+		return m
+	}
+	if m == nil {
+		m = map[string]string{}
+	}
+	for k, v := range desired {
+		m[k] = v
+	}
+	return m
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OutputNamespaces resolves the namespaces an output is written to
+type OutputNamespaces struct {
+	// Default is the namespace of outputs that don't specify one
+	Default string
+	// Allowed lists the namespaces outputs may be written to
+	// in addition to the default namespace. "*" allows any namespace.
+	Allowed []string
+	// Client lists the namespaces matching an output's namespaceSelector
+	Client client.Reader
+}
+
+func (n *OutputNamespaces) isAllowed(namespace string) bool {
+	if namespace == n.Default {
+		return true
+	}
+	for _, ns := range n.Allowed {
+		if ns == namespace || ns == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the namespaces the output is written to.
+// Namespaces that match the output's selector but are not allowed are skipped.
+func (n *OutputNamespaces) resolve(out ktransformv1alpha1.Output) ([]string, error) {
+	if out.NamespaceSelector == nil {
+		ns := out.Namespace
+		if ns == "" {
+			ns = n.Default
+		}
+		if ns == "" {
+			return nil, fmt.Errorf("%w for output", ErrMissingNamespace)
+		}
+		if !n.isAllowed(ns) {
+			return nil, fmt.Errorf("%w: %s", ErrOutputNamespaceNotAllowed, ns)
+		}
+		return []string{ns}, nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(out.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("%w: namespaceSelector: %s", ErrInvalidSelector, err)
+	}
+	if n.Client == nil {
+		return nil, fmt.Errorf("%w: cannot list namespaces", ErrOutputNamespaceNotAllowed)
+	}
+	l := &corev1.NamespaceList{}
+	if err = n.Client.List(context.TODO(), l, client.MatchingLabelsSelector{Selector: sel}); err != nil {
+		return nil, fmt.Errorf("list namespaces: %w", err)
+	}
+	namespaces := make([]string, 0, len(l.Items))
+	for _, ns := range l.Items {
+		if ns.DeletionTimestamp.IsZero() && n.isAllowed(ns.Name) {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+type objectReader struct {
+	scheme  *runtime.Scheme
+	objects []*unstructured.Unstructured
+}
+
+// NewObjectReader returns a client.Reader that reads the given objects.
+// It allows to load inputs without a cluster.
+func NewObjectReader(scheme *runtime.Scheme, objects []*unstructured.Unstructured) client.Reader {
+	return &objectReader{scheme, objects}
+}
+
+func (r *objectReader) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return err
+	}
+	for _, o := range r.objects {
+		if o.GroupVersionKind() == gvk && o.GetNamespace() == key.Namespace && o.GetName() == key.Name {
+			return fromUnstructured(o.DeepCopy().Object, obj)
+		}
+	}
+	gr, _ := meta.UnsafeGuessKindToResource(gvk)
+	return errors.NewNotFound(gr.GroupResource(), key.Name)
+}
+
+func (r *objectReader) List(_ context.Context, list runtime.Object, opts ...client.ListOption) error {
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	gvk, err := apiutil.GVKForObject(list, r.scheme)
+	if err != nil {
+		return err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	sel := listOpts.LabelSelector
+	if sel == nil {
+		sel = labels.Everything()
+	}
+	items := []interface{}{}
+	for _, o := range r.objects {
+		if o.GroupVersionKind() == gvk &&
+			(listOpts.Namespace == "" || o.GetNamespace() == listOpts.Namespace) &&
+			sel.Matches(labels.Set(o.GetLabels())) {
+			items = append(items, o.DeepCopy().Object)
+		}
+	}
+	if u, ok := list.(*unstructured.UnstructuredList); ok {
+		u.Items = make([]unstructured.Unstructured, len(items))
+		for i, item := range items {
+			u.Items[i].Object = item.(map[string]interface{})
+		}
+		return nil
+	}
+	return fromUnstructured(map[string]interface{}{"items": items}, list)
+}
+
+func fromUnstructured(m map[string]interface{}, obj runtime.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		u.Object = m
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(m, obj)
+}
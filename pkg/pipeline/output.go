@@ -0,0 +1,356 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type Resource interface {
+	metav1.Object
+	runtime.Object
+}
+
+// Output is a transformed output.
+// Its Resource contains only the object's identity until Apply is called
+// in order to allow to apply the transformation to an existing object.
+type Output struct {
+	Resource    Resource
+	Apply       func() error
+	PrunePolicy ktransformv1alpha1.PrunePolicy
+	WriteMode   ktransformv1alpha1.WriteMode
+	// RenewAt is the time the output must be transformed again at
+	// in order to renew its certificate (set by Apply)
+	RenewAt time.Time
+	// Warning reports a problem that does not prevent the output from being written (set by Apply)
+	Warning string
+	// Index of the output within the spec
+	Index int
+	// Err is set when the output could not be transformed (or written)
+	Err    error
+	Reason status.ConditionReason
+	// clone returns a copy of the transformed output with a new Resource
+	clone func() *Output
+}
+
+// Transform transforms all outputs.
+// An output that cannot be transformed is returned with an error.
+// An output that specifies items is returned as one Output per item.
+// An output is transformed once and returned once per namespace it is written to.
+// When namespaces is nil the outputs' namespaces are not resolved and left empty.
+// When observe is not nil it is called with the duration of each query evaluation.
+func Transform(inputs func() map[string]interface{}, outputs []ktransformv1alpha1.Output, namespaces *OutputNamespaces, observe func(time.Duration)) []*Output {
+	result := make([]*Output, 0, len(outputs))
+	for i, out := range outputs {
+		var transformed []*Output
+		targets := []string{""}
+		err := specError(validateOutput(&outputs[i], field.NewPath("spec", "output").Index(i)))
+		if err == nil && namespaces != nil {
+			targets, err = namespaces.resolve(out)
+		}
+		if err == nil {
+			if transformed, err = transformOutput(inputs(), out, observe); err == nil {
+				transformed = inNamespaces(transformed, targets)
+			}
+		}
+		if err != nil {
+			reason := ktransformv1alpha1.ReasonFailedTransform
+			if IsSpecError(err) {
+				reason = ktransformv1alpha1.ReasonInvalidSpec
+			}
+			transformed = []*Output{{Err: fmt.Errorf("output %d: %w", i, err), Reason: reason}}
+		}
+		for _, o := range transformed {
+			o.PrunePolicy = out.PrunePolicy
+			o.WriteMode = out.WriteMode
+			o.Index = i
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+// inNamespaces returns a copy of each output per namespace
+func inNamespaces(outputs []*Output, namespaces []string) []*Output {
+	result := make([]*Output, 0, len(outputs)*len(namespaces))
+	for i, ns := range namespaces {
+		for _, o := range outputs {
+			if i > 0 {
+				o = o.clone()
+			}
+			o.Resource.SetNamespace(ns)
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+func transformOutput(inputs map[string]interface{}, out ktransformv1alpha1.Output, observe func(time.Duration)) ([]*Output, error) {
+	if out.Items != "" {
+		return transformItems(inputs, out, observe)
+	}
+	o, err := transformResource(inputs, out, observe)
+	if err != nil {
+		return nil, err
+	}
+	return []*Output{o}, nil
+}
+
+func transformResource(inputs map[string]interface{}, out ktransformv1alpha1.Output, observe func(time.Duration)) (*Output, error) {
+	engine, hashes, err := outputEngine(out, observe)
+	if err != nil {
+		return nil, err
+	}
+	if out.Object != "" {
+		return transformObject(inputs, engine, hashes, out.Object)
+	}
+	formats, err := outputFormats(out.Formats)
+	if err != nil {
+		return nil, err
+	}
+	transformed, err := queryData(inputs, engine, out.DataTransformation)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := queryMap(inputs, engine, out.Transformation)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range keys {
+		transformed[k] = v
+	}
+	if out.ConfigMap != nil {
+		metadata, err := transformMetadata(inputs, engine, out.ConfigMap.OutputMetadata)
+		if err != nil {
+			return nil, err
+		}
+		m, err := transform.StringMapFromOutput(transformed, formats)
+		if err != nil {
+			return nil, err
+		}
+		return newConfigMapOutput(out.ConfigMap.Name, m, metadata, hashes), nil
+	}
+	metadata, err := transformMetadata(inputs, engine, out.Secret.OutputMetadata)
+	if err != nil {
+		return nil, err
+	}
+	m, err := transform.BytesMapFromOutput(transformed, formats)
+	if err != nil {
+		return nil, err
+	}
+	var cert *certificateRequest
+	secretType := out.Secret.Type
+	if out.Secret.Certificate != nil {
+		if cert, err = transformCertificate(inputs, engine, out.Secret.Certificate); err != nil {
+			return nil, fmt.Errorf("certificate: %w", err)
+		}
+		if secretType == "" {
+			secretType = corev1.SecretTypeTLS
+		}
+	}
+	return newSecretOutput(out.Secret.Name, secretType, m, metadata, cert, hashes), nil
+}
+
+// outputEngine returns the output's engine that records the bcrypt hashes it generates
+// and reports the duration of its evaluations to observe
+func outputEngine(out ktransformv1alpha1.Output, observe func(time.Duration)) (transform.Engine, *transform.Hashes, error) {
+	engine, err := transform.EngineByName(string(out.Engine))
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := transform.NewHashes()
+	return transform.WithObserver(transform.WithHashes(engine, hashes), observe), hashes, nil
+}
+
+func outputFormats(formats map[string]ktransformv1alpha1.OutputFormat) (map[string]string, error) {
+	m := make(map[string]string, len(formats))
+	for k, f := range formats {
+		if !transform.IsOutputFormat(string(f)) {
+			return nil, fmt.Errorf("%w %q for key %s", ErrUnknownFormat, f, k)
+		}
+		m[k] = string(f)
+	}
+	return m, nil
+}
+
+func newConfigMapOutput(name string, data map[string]string, metadata outputMetadata, hashes *transform.Hashes) *Output {
+	cm := &corev1.ConfigMap{}
+	cm.Name = name
+	return &Output{Resource: cm, Apply: func() error {
+		existing := cm.Data
+		cm.Data = make(map[string]string, len(data))
+		for k, v := range data {
+			cm.Data[k] = hashes.Reuse(v, existing[k])
+		}
+		metadata.Apply(cm)
+		return nil
+	}, clone: func() *Output {
+		return newConfigMapOutput(name, data, metadata, hashes)
+	}}
+}
+
+func newSecretOutput(name string, secretType corev1.SecretType, data map[string][]byte, metadata outputMetadata, cert *certificateRequest, hashes *transform.Hashes) *Output {
+	sec := &corev1.Secret{}
+	sec.Name = name
+	o := &Output{Resource: sec, clone: func() *Output {
+		return newSecretOutput(name, secretType, data, metadata, cert, hashes)
+	}}
+	o.Apply = func() error {
+		if sec.CreationTimestamp.IsZero() {
+			// the type of an existing Secret cannot be changed
+			sec.Type = secretType
+		}
+		existing := sec.Data
+		sec.Data = make(map[string][]byte, len(data))
+		for k, v := range data {
+			sec.Data[k] = []byte(hashes.Reuse(string(v), string(existing[k])))
+		}
+		metadata.Apply(sec)
+		if cert != nil {
+			if sec.Data == nil {
+				sec.Data = map[string][]byte{}
+			}
+			renewAt, warning, err := cert.apply(sec, existing)
+			if err != nil {
+				return fmt.Errorf("issue certificate: %w", err)
+			}
+			o.RenewAt = renewAt
+			o.Warning = warning
+		}
+		return nil
+	}
+	return o
+}
+
+func queryMap(inputs map[string]interface{}, engine transform.Engine, queries map[string]string) (map[string]interface{}, error) {
+	transformed := map[string]interface{}{}
+	for k, query := range queries {
+		v, err := transform.Evaluate(engine, inputs, query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		transformed[k] = v
+	}
+	return transformed, nil
+}
+
+// queryData evaluates a query that returns an object whose entries are output keys
+func queryData(inputs map[string]interface{}, engine transform.Engine, query string) (map[string]interface{}, error) {
+	if query == "" {
+		return map[string]interface{}{}, nil
+	}
+	v, err := transform.Evaluate(engine, inputs, query)
+	if err != nil {
+		return nil, fmt.Errorf("dataTransformation: %w", err)
+	}
+	if v == nil {
+		return map[string]interface{}{}, nil
+	}
+	m, err := transform.ObjectFromOutput(v)
+	if err != nil {
+		return nil, fmt.Errorf("dataTransformation: %w", err)
+	}
+	for k := range m {
+		if errs := validation.IsConfigMapKey(k); len(errs) > 0 {
+			err = &transform.ResultError{Reason: "invalid key", Err: fmt.Errorf("invalid key %q: %s", k, errs[0])}
+			return nil, fmt.Errorf("dataTransformation: %w", err)
+		}
+	}
+	return m, nil
+}
+
+func transformObject(inputs map[string]interface{}, engine transform.Engine, hashes *transform.Hashes, query string) (*Output, error) {
+	v, err := transform.Evaluate(engine, inputs, query)
+	if err != nil {
+		return nil, fmt.Errorf("object: %w", err)
+	}
+	m, err := transform.ObjectFromOutput(v)
+	if err != nil {
+		return nil, &transform.ResultError{Reason: ErrInvalidObject.Error(), Err: fmt.Errorf("%w: %s", ErrInvalidObject, err)}
+	}
+	desired := &unstructured.Unstructured{Object: m}
+	if desired.GetAPIVersion() == "" || desired.GetKind() == "" || desired.GetName() == "" {
+		return nil, fmt.Errorf("%w: apiVersion, kind and metadata.name must be set", ErrInvalidObject)
+	}
+	return newObjectOutput(desired, hashes), nil
+}
+
+func newObjectOutput(desired *unstructured.Unstructured, hashes *transform.Hashes) *Output {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(desired.GroupVersionKind())
+	obj.SetName(desired.GetName())
+	// Apply replaces the (existing) object with the server-side apply configuration
+	return &Output{Resource: obj, Apply: func() error {
+		d := desired.DeepCopy()
+		reuseHashes(hashes, d.Object, obj.Object)
+		applyObject(obj, d)
+		return nil
+	}, clone: func() *Output {
+		return newObjectOutput(desired, hashes)
+	}}
+}
+
+// applyObject sets obj to the desired object in order to server-side apply it:
+// fields, labels and annotations that are not desired anymore are removed
+// by the API server while fields set by others, e.g. defaults, are kept.
+// Of the desired metadata only the name, labels and annotations are applied.
+func applyObject(obj, desired *unstructured.Unstructured) {
+	namespace := obj.GetNamespace()
+	obj.Object = make(map[string]interface{}, len(desired.Object))
+	for k, v := range desired.Object {
+		if k != "metadata" && k != "status" {
+			obj.Object[k] = v
+		}
+	}
+	obj.SetName(desired.GetName())
+	obj.SetNamespace(namespace)
+	obj.SetLabels(desired.GetLabels())
+	obj.SetAnnotations(desired.GetAnnotations())
+}
+
+// reuseHashes replaces the generated bcrypt hashes within the desired object
+// with the hashes at the same path within the existing object that match the same password
+func reuseHashes(hashes *transform.Hashes, desired, existing interface{}) interface{} {
+	switch d := desired.(type) {
+	case string:
+		e, _ := existing.(string)
+		return hashes.Reuse(d, e)
+	case map[string]interface{}:
+		e, _ := existing.(map[string]interface{})
+		for k, v := range d {
+			d[k] = reuseHashes(hashes, v, e[k])
+		}
+	case []interface{}:
+		e, _ := existing.([]interface{})
+		for i, v := range d {
+			var ev interface{}
+			if i < len(e) {
+				ev = e[i]
+			}
+			d[i] = reuseHashes(hashes, v, ev)
+		}
+	}
+	return desired
+}
+
+func mergeMaps(m, add map[string]string) map[string]string {
+	if len(add) == 0 {
+		return m
+	}
+	if m == nil {
+		m = map[string]string{}
+	}
+	for k, v := range add {
+		m[k] = v
+	}
+	return m
+}
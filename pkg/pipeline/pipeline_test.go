@@ -0,0 +1,247 @@
+package pipeline
+
+import (
+	goerrors "errors"
+	"testing"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+func testObject(kind, name string, labels map[string]interface{}, data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "myns", "labels": labels},
+		"data":       data,
+	}}
+}
+
+func TestTransform(t *testing.T) {
+	secretName := "mysecret"
+	configMapName := "myconfig"
+	objects := []*unstructured.Unstructured{
+		testObject("Secret", secretName, nil, map[string]interface{}{"user": "dXNy"}),
+		testObject("Secret", "other", map[string]interface{}{"team": "x"}, map[string]interface{}{"user": "b3RoZXI="}),
+		testObject("ConfigMap", configMapName, nil, map[string]interface{}{"conf": "host: example.org"}),
+	}
+	loader := &InputLoader{Client: NewObjectReader(clientgoscheme.Scheme, objects)}
+	inputs := map[string]ktransformv1alpha1.InputRef{
+		"sec":  {Secret: &secretName},
+		"sel":  {SecretSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "x"}}},
+		"conf": {ConfigMap: &configMapName},
+	}
+	refs, scope, err := loader.Load("myns", inputs)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(refs), "refs")
+
+	outputs := Transform(scope, []ktransformv1alpha1.Output{
+		{
+			Secret: &ktransformv1alpha1.SecretOutput{Name: "out"},
+			Transformation: map[string]string{
+				"user":  ".sec.user.string",
+				"users": `.sel | keys | join(",")`,
+				"host":  ".conf.conf.object.host",
+			},
+		},
+		{
+			ConfigMap:      &ktransformv1alpha1.ConfigMapOutput{Name: "fail"},
+			Transformation: map[string]string{"invalid": "invalid("},
+		},
+	}, nil, nil)
+	require.Equal(t, 2, len(outputs), "outputs")
+	require.NoError(t, outputs[0].Err)
+	require.NoError(t, outputs[0].Apply())
+	sec, ok := outputs[0].Resource.(*corev1.Secret)
+	require.True(t, ok, "output should be Secret")
+	require.Equal(t, map[string][]byte{
+		"user":  []byte("usr"),
+		"users": []byte("other"),
+		"host":  []byte("example.org"),
+	}, sec.Data)
+	require.Error(t, outputs[1].Err)
+	require.Equal(t, ktransformv1alpha1.ReasonInvalidSpec, outputs[1].Reason)
+}
+
+func TestTransformDataTransformation(t *testing.T) {
+	scope := func() map[string]interface{} {
+		return map[string]interface{}{"registries": []interface{}{"a.example.org", "b.example.org"}}
+	}
+	outputs := Transform(scope, []ktransformv1alpha1.Output{
+		{
+			ConfigMap:          &ktransformv1alpha1.ConfigMapOutput{Name: "out"},
+			DataTransformation: `.registries | map({key: (. + ".conf"), value: {host: .}}) | from_entries`,
+			Transformation:     map[string]string{"b.example.org.conf": `"overwritten"`, "count": ".registries | length"},
+			Formats:            map[string]ktransformv1alpha1.OutputFormat{"a.example.org.conf": "yaml"},
+		},
+		{
+			ConfigMap:          &ktransformv1alpha1.ConfigMapOutput{Name: "invalidkey"},
+			DataTransformation: `{"invalid/key": "x"}`,
+		},
+	}, nil, nil)
+	require.NoError(t, outputs[0].Err)
+	require.NoError(t, outputs[0].Apply())
+	require.Equal(t, map[string]string{
+		"a.example.org.conf": "host: a.example.org\n",
+		"b.example.org.conf": "overwritten",
+		"count":              "2",
+	}, outputs[0].Resource.(*corev1.ConfigMap).Data)
+	require.Error(t, outputs[1].Err, "invalid key")
+	require.Equal(t, ktransformv1alpha1.ReasonFailedTransform, outputs[1].Reason)
+}
+
+func TestTransformReusesBcryptHash(t *testing.T) {
+	scope := func() map[string]interface{} {
+		return map[string]interface{}{"password": "secret"}
+	}
+	spec := []ktransformv1alpha1.Output{{
+		Secret:         &ktransformv1alpha1.SecretOutput{Name: "out"},
+		Transformation: map[string]string{"auth": `htpasswd("usr"; .password)`},
+	}}
+	outputs := Transform(scope, spec, nil, nil)
+	require.NoError(t, outputs[0].Err)
+	require.NoError(t, outputs[0].Apply())
+	existing := outputs[0].Resource.(*corev1.Secret)
+	outputs = Transform(scope, spec, nil, nil)
+	require.NoError(t, outputs[0].Err)
+	sec := outputs[0].Resource.(*corev1.Secret)
+	sec.Data = existing.Data
+	require.NoError(t, outputs[0].Apply())
+	require.Equal(t, string(existing.Data["auth"]), string(sec.Data["auth"]), "should reuse existing hash")
+}
+
+func TestTransformItems(t *testing.T) {
+	scope := func() map[string]interface{} {
+		return map[string]interface{}{"tenants": []interface{}{
+			map[string]interface{}{"name": "a", "user": "usr-a"},
+			map[string]interface{}{"name": "b", "user": "usr-b"},
+		}}
+	}
+	outputs := Transform(scope, []ktransformv1alpha1.Output{
+		{
+			Secret: &ktransformv1alpha1.SecretOutput{OutputMetadata: ktransformv1alpha1.OutputMetadata{
+				Labels: map[string]string{"app": "x"},
+			}},
+			Items:   `.tenants | map({name: "tenant-\(.name)", data: {user: .user, conf: {tenant: .name}}, labels: {tenant: .name}})`,
+			Formats: map[string]ktransformv1alpha1.OutputFormat{"conf": "yaml"},
+		},
+		{
+			ConfigMap: &ktransformv1alpha1.ConfigMapOutput{},
+			Items:     `[{name: "dup"}, {name: "dup"}]`,
+		},
+		{
+			ConfigMap: &ktransformv1alpha1.ConfigMapOutput{},
+			Items:     `null`,
+		},
+	}, nil, nil)
+	require.Equal(t, 3, len(outputs), "outputs")
+	for i, tenant := range []string{"a", "b"} {
+		o := outputs[i]
+		require.NoError(t, o.Err)
+		require.Equal(t, 0, o.Index, "index")
+		require.NoError(t, o.Apply())
+		sec := o.Resource.(*corev1.Secret)
+		require.Equal(t, "tenant-"+tenant, sec.Name, "name")
+		require.Equal(t, map[string][]byte{
+			"user": []byte("usr-" + tenant),
+			"conf": []byte("tenant: " + tenant + "\n"),
+		}, sec.Data, "data")
+		require.Equal(t, map[string]string{"app": "x", "tenant": tenant}, sec.Labels, "labels")
+	}
+	require.Error(t, outputs[2].Err, "duplicate names")
+	require.Equal(t, 1, outputs[2].Index, "index")
+}
+
+func TestTransformNamespaces(t *testing.T) {
+	namespace := func(name string, labels map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]interface{}{"name": name, "labels": labels},
+		}}
+	}
+	objects := []*unstructured.Unstructured{
+		namespace("team-a", map[string]interface{}{"pull-secret": "true"}),
+		namespace("team-b", map[string]interface{}{"pull-secret": "true"}),
+		namespace("team-c", nil),
+		namespace("restricted", map[string]interface{}{"pull-secret": "true"}),
+	}
+	namespaces := &OutputNamespaces{
+		Default: "myns",
+		Allowed: []string{"team-a", "team-b", "team-c"},
+		Client:  NewObjectReader(clientgoscheme.Scheme, objects),
+	}
+	scope := func() map[string]interface{} { return map[string]interface{}{} }
+	transformation := map[string]string{"k": `"v"`}
+	outputs := Transform(scope, []ktransformv1alpha1.Output{
+		{Secret: &ktransformv1alpha1.SecretOutput{Name: "default"}, Transformation: transformation},
+		{Secret: &ktransformv1alpha1.SecretOutput{Name: "explicit"}, Transformation: transformation, Namespace: "team-c"},
+		{
+			Secret:            &ktransformv1alpha1.SecretOutput{Name: "pull-secret"},
+			Transformation:    map[string]string{"k": `"v"`, "hash": `"pw" | bcrypt`},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pull-secret": "true"}},
+		},
+		{Secret: &ktransformv1alpha1.SecretOutput{Name: "denied"}, Transformation: transformation, Namespace: "restricted"},
+	}, namespaces, nil)
+	written := []string{}
+	for _, o := range outputs[:4] {
+		require.NoError(t, o.Err)
+		written = append(written, o.Resource.GetNamespace()+"/"+o.Resource.GetName())
+	}
+	require.Equal(t, []string{"myns/default", "team-c/explicit", "team-a/pull-secret", "team-b/pull-secret"}, written)
+	require.NoError(t, outputs[2].Apply())
+	require.NoError(t, outputs[3].Apply())
+	require.NotSame(t, outputs[2].Resource, outputs[3].Resource, "output per namespace")
+	hashA := outputs[2].Resource.(*corev1.Secret).Data["hash"]
+	hashB := outputs[3].Resource.(*corev1.Secret).Data["hash"]
+	require.Equal(t, string(hashA), string(hashB), "output should be transformed once for all namespaces")
+	require.Equal(t, 5, len(outputs), "outputs")
+	require.Error(t, outputs[4].Err, "not allowed namespace")
+	require.Equal(t, ktransformv1alpha1.ReasonInvalidSpec, outputs[4].Reason)
+}
+
+func TestLoadMissingInput(t *testing.T) {
+	name := "missing"
+	loader := &InputLoader{Client: NewObjectReader(clientgoscheme.Scheme, nil)}
+	_, _, err := loader.Load("myns", map[string]ktransformv1alpha1.InputRef{"sec": {Secret: &name}})
+	require.Error(t, err)
+	var statusErr *errors.StatusError
+	require.True(t, goerrors.As(err, &statusErr) && errors.IsNotFound(statusErr), "should be NotFound: %v", err)
+}
+
+func TestLoadNamespaceNotAllowed(t *testing.T) {
+	name := "mysecret"
+	loader := &InputLoader{Client: NewObjectReader(clientgoscheme.Scheme, nil)}
+	_, _, err := loader.Load("myns", map[string]ktransformv1alpha1.InputRef{"sec": {Secret: &name, Namespace: "other"}})
+	require.True(t, IsSpecError(err), "should be spec error: %v", err)
+}
+
+func TestTransformObjectApplyConfiguration(t *testing.T) {
+	scope := func() map[string]interface{} { return map[string]interface{}{} }
+	outputs := Transform(scope, []ktransformv1alpha1.Output{{
+		Object: `{apiVersion: "v1", kind: "Service", metadata: {name: "a", labels: {app: "x"}}, spec: {ports: [{port: 80}]}}`,
+	}}, nil, nil)
+	require.NoError(t, outputs[0].Err)
+	obj := outputs[0].Resource.(*unstructured.Unstructured)
+	obj.SetNamespace("myns")
+	obj.SetResourceVersion("1")
+	obj.SetLabels(map[string]string{"obsolete": "y"})
+	obj.Object["status"] = map[string]interface{}{"loadBalancer": map[string]interface{}{}}
+	obj.Object["obsolete"] = "y"
+	require.NoError(t, outputs[0].Apply())
+	require.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      "a",
+			"namespace": "myns",
+			"labels":    map[string]interface{}{"app": "x"},
+		},
+		"spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": int64(80)}}},
+	}, obj.Object, "apply configuration should contain the desired fields only")
+}
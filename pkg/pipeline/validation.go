@@ -0,0 +1,373 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/transform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// specError returns an ErrInvalidSpec error that lists the given errors or nil if there are none
+func specError(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidSpec, errs.ToAggregate())
+}
+
+// ValidateSpec returns the errors within the spec that can be detected
+// without reading the inputs.
+// The pipeline validates the inputs, generators and outputs it processes using the same rules.
+func ValidateSpec(s *ktransformv1alpha1.SecretTransformSpec, path *field.Path) (errs field.ErrorList) {
+	inputNames := make([]string, 0, len(s.Input))
+	for k := range s.Input {
+		inputNames = append(inputNames, k)
+	}
+	sort.Strings(inputNames)
+	for _, k := range inputNames {
+		errs = append(errs, validateInput(s.Input[k], path.Child("input").Key(k))...)
+	}
+	generatorNames := make([]string, 0, len(s.Generate))
+	for k := range s.Generate {
+		generatorNames = append(generatorNames, k)
+	}
+	sort.Strings(generatorNames)
+	for _, k := range generatorNames {
+		errs = append(errs, validateGenerator(k, s.Generate[k], s.Input, path.Child("generate").Key(k))...)
+	}
+	names := map[string]*field.Path{}
+	for i, out := range s.Output {
+		p := path.Child("output").Index(i)
+		errs = append(errs, validateOutput(&out, p)...)
+		if out.Secret != nil && out.Secret.Certificate != nil {
+			if issuer := out.Secret.Certificate.Issuer; s.Generate[issuer].CA == nil {
+				errs = append(errs, field.Invalid(p.Child("secret", "certificate", "issuer"), issuer, "must refer to a ca generator"))
+			}
+		}
+		kind, namePath, name := "", p, ""
+		switch {
+		case out.Secret != nil && out.ConfigMap == nil:
+			kind, namePath, name = "Secret", p.Child("secret", "name"), out.Secret.Name
+		case out.ConfigMap != nil && out.Secret == nil:
+			kind, namePath, name = "ConfigMap", p.Child("configMap", "name"), out.ConfigMap.Name
+		}
+		if name == "" {
+			continue
+		}
+		key := kind + "/" + out.Namespace + "/" + name
+		if first, ok := names[key]; ok {
+			errs = append(errs, field.Duplicate(namePath, fmt.Sprintf("%s (also specified by %s)", name, first)))
+			continue
+		}
+		names[key] = namePath
+	}
+	return
+}
+
+// ValidateClusterScopedSpec returns the errors within the spec of a ClusterSecretTransform.
+// In addition to the SecretTransform constraints all inputs and outputs must specify
+// a namespace and generators are not supported since there is no namespace to store their state in.
+func ValidateClusterScopedSpec(s *ktransformv1alpha1.SecretTransformSpec, path *field.Path) field.ErrorList {
+	errs := ValidateSpec(s, path)
+	inputNames := make([]string, 0, len(s.Input))
+	for k := range s.Input {
+		inputNames = append(inputNames, k)
+	}
+	sort.Strings(inputNames)
+	for _, k := range inputNames {
+		if s.Input[k].Namespace == "" {
+			errs = append(errs, field.Required(path.Child("input").Key(k).Child("namespace"), "namespace must be specified within a cluster-scoped transform"))
+		}
+	}
+	if len(s.Generate) > 0 {
+		errs = append(errs, field.Forbidden(path.Child("generate"), "generators are not supported within a cluster-scoped transform"))
+	}
+	for i, out := range s.Output {
+		if out.Namespace == "" && out.NamespaceSelector == nil {
+			errs = append(errs, field.Required(path.Child("output").Index(i).Child("namespace"), "namespace or namespaceSelector must be specified within a cluster-scoped transform"))
+		}
+	}
+	return errs
+}
+
+func validateInput(in ktransformv1alpha1.InputRef, path *field.Path) (errs field.ErrorList) {
+	specified := 0
+	isObjectRef := in.APIVersion != "" || in.Kind != "" || in.Name != ""
+	for _, isSet := range []bool{in.Secret != nil, in.ConfigMap != nil, in.SecretSelector != nil, in.ConfigMapSelector != nil, isObjectRef} {
+		if isSet {
+			specified++
+		}
+	}
+	switch {
+	case specified > 1:
+		errs = append(errs, field.Invalid(path, "", "only one of secret, configMap, secretSelector, configMapSelector or kind must be specified"))
+	case specified == 0:
+		errs = append(errs, field.Required(path, "neither secret, configMap, secretSelector, configMapSelector nor kind specified"))
+	case in.Secret != nil && *in.Secret == "":
+		errs = append(errs, field.Required(path.Child("secret"), ""))
+	case in.ConfigMap != nil && *in.ConfigMap == "":
+		errs = append(errs, field.Required(path.Child("configMap"), ""))
+	case isObjectRef:
+		for _, f := range []struct {
+			name  string
+			value string
+		}{{"apiVersion", in.APIVersion}, {"kind", in.Kind}, {"name", in.Name}} {
+			if f.value == "" {
+				errs = append(errs, field.Required(path.Child(f.name), "apiVersion, kind and name must be specified"))
+			}
+		}
+	}
+	if isObjectRef && len(in.Formats) > 0 {
+		errs = append(errs, field.Invalid(path.Child("formats"), "", "formats can only be specified for Secret and ConfigMap inputs"))
+	}
+	errs = append(errs, validateSelector(path.Child("secretSelector"), in.SecretSelector)...)
+	errs = append(errs, validateSelector(path.Child("configMapSelector"), in.ConfigMapSelector)...)
+	return
+}
+
+// validateGenerator validates a generator and its name which must not be used by an input
+func validateGenerator(name string, g ktransformv1alpha1.Generator, inputs map[string]ktransformv1alpha1.InputRef, path *field.Path) (errs field.ErrorList) {
+	for _, msg := range validation.IsConfigMapKey(name) {
+		errs = append(errs, field.Invalid(path, name, msg))
+	}
+	if _, ok := inputs[name]; ok {
+		errs = append(errs, field.Duplicate(path, fmt.Sprintf("%s (also specified as input)", name)))
+	}
+	specified := 0
+	for _, isSet := range []bool{g.RandomString != nil, g.PrivateKey != nil, g.UUID != nil, g.CA != nil} {
+		if isSet {
+			specified++
+		}
+	}
+	switch {
+	case specified > 1:
+		errs = append(errs, field.Invalid(path, "", "only one of randomString, privateKey, uuid or ca must be specified"))
+	case specified == 0:
+		errs = append(errs, field.Required(path, "neither randomString, privateKey, uuid nor ca specified"))
+	case g.RandomString != nil:
+		if l := g.RandomString.Length; l < 0 || l > maxRandomStringLength {
+			errs = append(errs, field.Invalid(path.Child("randomString", "length"), l, fmt.Sprintf("must be between 1 and %d", maxRandomStringLength)))
+		}
+	case g.PrivateKey != nil:
+		errs = append(errs, validatePrivateKey(path.Child("privateKey"), g.PrivateKey)...)
+	case g.CA != nil:
+		errs = append(errs, validatePrivateKey(path.Child("ca", "privateKey"), g.CA.PrivateKey)...)
+	}
+	return
+}
+
+func validatePrivateKey(path *field.Path, key *ktransformv1alpha1.PrivateKeyGenerator) field.ErrorList {
+	if key != nil && !validKeySize(key.Type, key.Bits) {
+		return field.ErrorList{field.NotSupported(path.Child("bits"), key.Bits, keySizes[key.Type])}
+	}
+	return nil
+}
+
+var keySizes = map[ktransformv1alpha1.KeyType][]string{
+	ktransformv1alpha1.KeyTypeRSA:     {"2048", "3072", "4096"},
+	ktransformv1alpha1.KeyTypeECDSA:   {"256", "384", "521"},
+	ktransformv1alpha1.KeyTypeEd25519: {},
+}
+
+func validKeySize(keyType ktransformv1alpha1.KeyType, bits int) bool {
+	if bits == 0 {
+		return true
+	}
+	for _, size := range keySizes[keyType] {
+		if size == strconv.Itoa(bits) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSelector(path *field.Path, selector *metav1.LabelSelector) field.ErrorList {
+	if selector == nil {
+		return nil
+	}
+	if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+		return field.ErrorList{field.Invalid(path, selector, err.Error())}
+	}
+	return nil
+}
+
+func validateOutput(out *ktransformv1alpha1.Output, path *field.Path) (errs field.ErrorList) {
+	engine, err := transform.EngineByName(string(out.Engine))
+	if err != nil {
+		return field.ErrorList{field.NotSupported(path.Child("engine"), out.Engine, []string{string(ktransformv1alpha1.EngineJQ), string(ktransformv1alpha1.EngineTemplate)})}
+	}
+	switch out.WriteMode {
+	case "", ktransformv1alpha1.WriteModeReplace, ktransformv1alpha1.WriteModeMerge:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("writeMode"), out.WriteMode, []string{string(ktransformv1alpha1.WriteModeReplace), string(ktransformv1alpha1.WriteModeMerge)}))
+	}
+	if out.Namespace != "" {
+		if out.NamespaceSelector != nil {
+			errs = append(errs, field.Invalid(path.Child("namespaceSelector"), "", "only one of namespace or namespaceSelector must be specified"))
+		}
+		for _, msg := range validation.IsDNS1123Label(out.Namespace) {
+			errs = append(errs, field.Invalid(path.Child("namespace"), out.Namespace, msg))
+		}
+	}
+	errs = append(errs, validateSelector(path.Child("namespaceSelector"), out.NamespaceSelector)...)
+	for i, target := range out.RolloutTargets {
+		p := path.Child("rolloutTargets").Index(i)
+		switch target.Kind {
+		case ktransformv1alpha1.RolloutTargetDeployment, ktransformv1alpha1.RolloutTargetStatefulSet, ktransformv1alpha1.RolloutTargetDaemonSet:
+		default:
+			errs = append(errs, field.NotSupported(p.Child("kind"), target.Kind, []string{string(ktransformv1alpha1.RolloutTargetDeployment), string(ktransformv1alpha1.RolloutTargetStatefulSet), string(ktransformv1alpha1.RolloutTargetDaemonSet)}))
+		}
+		if target.Name == "" {
+			errs = append(errs, field.Required(p.Child("name"), ""))
+		}
+	}
+	if out.Object != "" {
+		if out.WriteMode == ktransformv1alpha1.WriteModeMerge {
+			errs = append(errs, field.Invalid(path.Child("writeMode"), out.WriteMode, "object cannot be merged"))
+		}
+		if out.Secret != nil || out.ConfigMap != nil {
+			errs = append(errs, field.Invalid(path, "", "only one of secret, configMap or object must be specified"))
+		}
+		if len(out.Transformation) > 0 {
+			errs = append(errs, field.Invalid(path.Child("transformation"), "", "object cannot be combined with transformation"))
+		}
+		if out.DataTransformation != "" {
+			errs = append(errs, field.Invalid(path.Child("dataTransformation"), "", "object cannot be combined with dataTransformation"))
+		}
+		if len(out.Formats) > 0 {
+			errs = append(errs, field.Invalid(path.Child("formats"), "", "object cannot be combined with formats"))
+		}
+		if out.Items != "" {
+			errs = append(errs, field.Invalid(path.Child("items"), "", "object cannot be combined with items"))
+		}
+		return append(errs, validateQuery(path.Child("object"), engine, out.Object)...)
+	}
+	if out.Items != "" {
+		return append(errs, validateItems(out, path, engine)...)
+	}
+	switch {
+	case out.Secret != nil && out.ConfigMap != nil:
+		errs = append(errs, field.Invalid(path, "", "only one of secret, configMap or object must be specified"))
+	case out.Secret == nil && out.ConfigMap == nil:
+		errs = append(errs, field.Required(path, "neither secret, configMap nor object specified"))
+	case out.Secret != nil:
+		errs = append(errs, validateSecretOutput(out.Secret, path.Child("secret"), engine)...)
+	case out.ConfigMap != nil:
+		errs = append(errs, validateConfigMapOutput(out.ConfigMap, path.Child("configMap"), engine)...)
+	}
+	if len(out.Transformation) == 0 && out.DataTransformation == "" && (out.Secret == nil || out.Secret.Certificate == nil) {
+		errs = append(errs, field.Required(path.Child("transformation"), "no transformation specified"))
+	}
+	if out.DataTransformation != "" {
+		errs = append(errs, validateQuery(path.Child("dataTransformation"), engine, out.DataTransformation)...)
+	}
+	for _, k := range sortedFormatKeys(out.Formats) {
+		if _, ok := out.Transformation[k]; !ok && out.DataTransformation == "" {
+			errs = append(errs, field.Invalid(path.Child("formats").Key(k), out.Formats[k], "no transformation specified for key"))
+		}
+	}
+	return append(errs, validateQueries(path.Child("transformation"), engine, out.Transformation)...)
+}
+
+// validateItems validates an output that writes one Secret or ConfigMap per item
+func validateItems(out *ktransformv1alpha1.Output, path *field.Path, engine transform.Engine) (errs field.ErrorList) {
+	switch {
+	case out.Secret != nil && out.ConfigMap != nil:
+		errs = append(errs, field.Invalid(path, "", "only one of secret or configMap must be specified"))
+	case out.Secret == nil && out.ConfigMap == nil:
+		errs = append(errs, field.Required(path, "neither secret nor configMap specified"))
+	case out.Secret != nil:
+		p := path.Child("secret")
+		if out.Secret.Name != "" {
+			errs = append(errs, field.Invalid(p.Child("name"), out.Secret.Name, "name cannot be combined with items"))
+		}
+		if out.Secret.Certificate != nil {
+			errs = append(errs, field.Invalid(p.Child("certificate"), "", "certificate cannot be combined with items"))
+		}
+		errs = append(errs, validateOutputMetadata(&out.Secret.OutputMetadata, p, engine)...)
+	case out.ConfigMap != nil:
+		p := path.Child("configMap")
+		if out.ConfigMap.Name != "" {
+			errs = append(errs, field.Invalid(p.Child("name"), out.ConfigMap.Name, "name cannot be combined with items"))
+		}
+		errs = append(errs, validateOutputMetadata(&out.ConfigMap.OutputMetadata, p, engine)...)
+	}
+	if len(out.Transformation) > 0 {
+		errs = append(errs, field.Invalid(path.Child("transformation"), "", "items cannot be combined with transformation"))
+	}
+	if out.DataTransformation != "" {
+		errs = append(errs, field.Invalid(path.Child("dataTransformation"), "", "items cannot be combined with dataTransformation"))
+	}
+	return append(errs, validateQuery(path.Child("items"), engine, out.Items)...)
+}
+
+func validateSecretOutput(o *ktransformv1alpha1.SecretOutput, path *field.Path, engine transform.Engine) (errs field.ErrorList) {
+	if o.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), ""))
+	}
+	if c := o.Certificate; c != nil {
+		p := path.Child("certificate")
+		if o.Type != "" && o.Type != corev1.SecretTypeTLS {
+			errs = append(errs, field.Invalid(path.Child("type"), o.Type, "certificate requires type "+string(corev1.SecretTypeTLS)))
+		}
+		if c.CommonName == "" && c.DNSNames == "" && c.IPAddresses == "" {
+			errs = append(errs, field.Required(p, "neither commonName, dnsNames nor ipAddresses specified"))
+		}
+		for _, q := range []struct {
+			name  string
+			query string
+		}{{"commonName", c.CommonName}, {"dnsNames", c.DNSNames}, {"ipAddresses", c.IPAddresses}} {
+			if q.query != "" {
+				errs = append(errs, validateQuery(p.Child(q.name), engine, q.query)...)
+			}
+		}
+		errs = append(errs, validatePrivateKey(p.Child("privateKey"), c.PrivateKey)...)
+	}
+	return append(errs, validateOutputMetadata(&o.OutputMetadata, path, engine)...)
+}
+
+func validateConfigMapOutput(o *ktransformv1alpha1.ConfigMapOutput, path *field.Path, engine transform.Engine) (errs field.ErrorList) {
+	if o.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), ""))
+	}
+	return append(errs, validateOutputMetadata(&o.OutputMetadata, path, engine)...)
+}
+
+func validateOutputMetadata(m *ktransformv1alpha1.OutputMetadata, path *field.Path, engine transform.Engine) field.ErrorList {
+	errs := validateQueries(path.Child("labelTransformation"), engine, m.LabelTransformation)
+	return append(errs, validateQueries(path.Child("annotationTransformation"), engine, m.AnnotationTransformation)...)
+}
+
+func sortedFormatKeys(formats map[string]ktransformv1alpha1.OutputFormat) []string {
+	keys := make([]string, 0, len(formats))
+	for k := range formats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateQueries(path *field.Path, engine transform.Engine, queries map[string]string) (errs field.ErrorList) {
+	keys := make([]string, 0, len(queries))
+	for k := range queries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		errs = append(errs, validateQuery(path.Key(k), engine, queries[k])...)
+	}
+	return
+}
+
+func validateQuery(path *field.Path, engine transform.Engine, query string) field.ErrorList {
+	if err := engine.Compile(query); err != nil {
+		return field.ErrorList{field.Invalid(path, query, err.Error())}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"testing"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateSpec(t *testing.T) {
+	name := "myinput"
+	validInput := map[string]ktransformv1alpha1.InputRef{"in": {Secret: &name}}
+	for _, c := range []struct {
+		name     string
+		spec     ktransformv1alpha1.SecretTransformSpec
+		expected []string
+	}{
+		{"valid", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": ".in.k.string"}},
+				{ConfigMap: &ktransformv1alpha1.ConfigMapOutput{Name: "a"}, Transformation: map[string]string{"k": ".in.k.string"}},
+				{Object: `{apiVersion: "v1", kind: "Service"}`},
+			},
+		}, nil},
+		{"ambiguous input", ktransformv1alpha1.SecretTransformSpec{
+			Input:  map[string]ktransformv1alpha1.InputRef{"in": {Secret: &name, ConfigMap: &name}},
+			Output: []ktransformv1alpha1.Output{{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}}},
+		}, []string{"spec.input[in]"}},
+		{"object input formats", ktransformv1alpha1.SecretTransformSpec{
+			Input:  map[string]ktransformv1alpha1.InputRef{"in": {APIVersion: "v1", Kind: "Service", Name: "svc", Formats: map[string]ktransformv1alpha1.InputFormat{"k": "toml"}}},
+			Output: []ktransformv1alpha1.Output{{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}}},
+		}, []string{"spec.input[in].formats"}},
+		{"ambiguous output", ktransformv1alpha1.SecretTransformSpec{
+			Input:  validInput,
+			Output: []ktransformv1alpha1.Output{{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, ConfigMap: &ktransformv1alpha1.ConfigMapOutput{Name: "a"}, Transformation: map[string]string{"k": "."}}},
+		}, []string{"spec.output[0]"}},
+		{"unspecified output", ktransformv1alpha1.SecretTransformSpec{
+			Input:  validInput,
+			Output: []ktransformv1alpha1.Output{{Transformation: map[string]string{"k": "."}}},
+		}, []string{"spec.output[0]"}},
+		{"empty transformation", ktransformv1alpha1.SecretTransformSpec{
+			Input:  validInput,
+			Output: []ktransformv1alpha1.Output{{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}}},
+		}, []string{"spec.output[0].transformation"}},
+		{"duplicate output name", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}},
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}},
+			},
+		}, []string{"spec.output[1].secret.name"}},
+		{"invalid query", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{{
+				Secret:         &ktransformv1alpha1.SecretOutput{Name: "a", OutputMetadata: ktransformv1alpha1.OutputMetadata{LabelTransformation: map[string]string{"l": "invalid("}}},
+				Transformation: map[string]string{"k": "invalid("},
+			}},
+		}, []string{"spec.output[0].secret.labelTransformation[l]", "spec.output[0].transformation[k]"}},
+		{"output formats", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{
+				{ConfigMap: &ktransformv1alpha1.ConfigMapOutput{Name: "a"}, Transformation: map[string]string{"k": "."}, Formats: map[string]ktransformv1alpha1.OutputFormat{"k": "yaml", "other": "json"}},
+				{Object: `{apiVersion: "v1", kind: "Service", metadata: {name: "a"}}`, Formats: map[string]ktransformv1alpha1.OutputFormat{"k": "yaml"}},
+			},
+		}, []string{"spec.output[0].formats[other]", "spec.output[1].formats"}},
+		{"data transformation", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{
+				{ConfigMap: &ktransformv1alpha1.ConfigMapOutput{Name: "a"}, DataTransformation: ".in | map_values(.string)", Formats: map[string]ktransformv1alpha1.OutputFormat{"dynamic": "yaml"}},
+				{ConfigMap: &ktransformv1alpha1.ConfigMapOutput{Name: "b"}, DataTransformation: "invalid("},
+			},
+		}, []string{"spec.output[1].dataTransformation"}},
+		{"output namespace", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}},
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}, Namespace: "other"},
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "b"}, Transformation: map[string]string{"k": "."}, Namespace: "Invalid_NS"},
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "c"}, Transformation: map[string]string{"k": "."}, Namespace: "other", NamespaceSelector: &metav1.LabelSelector{}},
+			},
+		}, []string{"spec.output[2].namespace", "spec.output[3].namespaceSelector"}},
+		{"rollout targets", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{{
+				Secret:         &ktransformv1alpha1.SecretOutput{Name: "a"},
+				Transformation: map[string]string{"k": "."},
+				RolloutTargets: []ktransformv1alpha1.RolloutTarget{{Kind: ktransformv1alpha1.RolloutTargetDeployment, Name: "app"}, {Kind: "Pod", Name: "app"}, {Kind: ktransformv1alpha1.RolloutTargetDaemonSet}},
+			}},
+		}, []string{"spec.output[0].rolloutTargets[1].kind", "spec.output[0].rolloutTargets[2].name"}},
+		{"write mode", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}, WriteMode: ktransformv1alpha1.WriteModeMerge},
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "b"}, Transformation: map[string]string{"k": "."}, WriteMode: "Patch"},
+				{Object: `{apiVersion: "v1", kind: "Service", metadata: {name: "a"}}`, WriteMode: ktransformv1alpha1.WriteModeMerge},
+			},
+		}, []string{"spec.output[1].writeMode", "spec.output[2].writeMode"}},
+		{"items", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{
+				{Secret: &ktransformv1alpha1.SecretOutput{}, Items: `[{name: "a", data: {k: "v"}}]`, Formats: map[string]ktransformv1alpha1.OutputFormat{"k": "yaml"}},
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "b"}, Items: ".", Transformation: map[string]string{"k": "."}},
+				{ConfigMap: &ktransformv1alpha1.ConfigMapOutput{}, Items: "invalid("},
+			},
+		}, []string{"spec.output[1].secret.name", "spec.output[1].transformation", "spec.output[2].items"}},
+		{"template engine", ktransformv1alpha1.SecretTransformSpec{
+			Input: validInput,
+			Output: []ktransformv1alpha1.Output{
+				{ConfigMap: &ktransformv1alpha1.ConfigMapOutput{Name: "a"}, Engine: ktransformv1alpha1.EngineTemplate, Transformation: map[string]string{"k": "{{ .in.k.string }}", "e": "{{ invalid"}},
+				{ConfigMap: &ktransformv1alpha1.ConfigMapOutput{Name: "b"}, Engine: "unknown", Transformation: map[string]string{"k": "."}},
+			},
+		}, []string{"spec.output[0].transformation[e]", "spec.output[1].engine"}},
+		{"invalid generators", ktransformv1alpha1.SecretTransformSpec{
+			Input:  validInput,
+			Output: []ktransformv1alpha1.Output{{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}}},
+			Generate: map[string]ktransformv1alpha1.Generator{
+				"in":      {UUID: &ktransformv1alpha1.UUIDGenerator{}},
+				"a/b":     {UUID: &ktransformv1alpha1.UUIDGenerator{}},
+				"empty":   {},
+				"keysize": {PrivateKey: &ktransformv1alpha1.PrivateKeyGenerator{Type: ktransformv1alpha1.KeyTypeECDSA, Bits: 2048}},
+				"valid":   {PrivateKey: &ktransformv1alpha1.PrivateKeyGenerator{Type: ktransformv1alpha1.KeyTypeRSA, Bits: 4096}},
+			},
+		}, []string{"spec.generate[a/b]", "spec.generate[empty]", "spec.generate[in]", "spec.generate[keysize].privateKey.bits"}},
+		{"certificate", ktransformv1alpha1.SecretTransformSpec{
+			Input:    validInput,
+			Generate: map[string]ktransformv1alpha1.Generator{"ca": {CA: &ktransformv1alpha1.CAGenerator{}}, "pw": {UUID: &ktransformv1alpha1.UUIDGenerator{}}},
+			Output: []ktransformv1alpha1.Output{
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "a", Certificate: &ktransformv1alpha1.CertificateOutput{Issuer: "ca", DNSNames: `["a", .in.host.string]`}}},
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "b", Type: "Opaque", Certificate: &ktransformv1alpha1.CertificateOutput{Issuer: "pw", CommonName: "invalid("}}},
+				{Secret: &ktransformv1alpha1.SecretOutput{Name: "c", Certificate: &ktransformv1alpha1.CertificateOutput{Issuer: "ca"}}},
+			},
+		}, []string{"spec.output[1].secret.type", "spec.output[1].secret.certificate.commonName", "spec.output[1].secret.certificate.issuer", "spec.output[2].secret.certificate"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			errs := ValidateSpec(&c.spec, field.NewPath("spec"))
+			fields := []string{}
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if c.expected == nil {
+				c.expected = []string{}
+			}
+			require.Equal(t, c.expected, fields, "invalid fields")
+		})
+	}
+}
+
+func TestValidateClusterScopedSpec(t *testing.T) {
+	name := "myinput"
+	spec := ktransformv1alpha1.SecretTransformSpec{
+		Input: map[string]ktransformv1alpha1.InputRef{
+			"in":   {Secret: &name, Namespace: "infra"},
+			"nons": {Secret: &name},
+		},
+		Generate: map[string]ktransformv1alpha1.Generator{"pw": {RandomString: &ktransformv1alpha1.RandomStringGenerator{Length: 8}}},
+		Output: []ktransformv1alpha1.Output{
+			{Secret: &ktransformv1alpha1.SecretOutput{Name: "a"}, Transformation: map[string]string{"k": "."}, Namespace: "app"},
+			{Secret: &ktransformv1alpha1.SecretOutput{Name: "b"}, Transformation: map[string]string{"k": "."}, NamespaceSelector: &metav1.LabelSelector{}},
+			{Secret: &ktransformv1alpha1.SecretOutput{Name: "c"}, Transformation: map[string]string{"k": "."}},
+		},
+	}
+	errs := ValidateClusterScopedSpec(&spec, field.NewPath("spec"))
+	fields := make([]string, len(errs))
+	for i, err := range errs {
+		fields[i] = err.Field
+	}
+	require.Equal(t, []string{"spec.input[nons].namespace", "spec.generate", "spec.output[2].namespace"}, fields)
+}
@@ -0,0 +1,83 @@
+package transform
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const bcryptMaxPassword = 72
+
+var (
+	bcryptPattern      = regexp.MustCompile(`\$2[abxy]?\$[0-9]{2}\$[./A-Za-z0-9]{53}`)
+	errPasswordTooLong = errors.New("bcrypt: password length exceeds 72 bytes")
+)
+
+// Hashes records the bcrypt hashes generated by the bcrypt and htpasswd
+// functions along with their passwords.
+// Since every hash is generated with a random salt an output would change
+// on every reconciliation. Reuse allows to keep the existing hash instead.
+type Hashes struct {
+	passwords map[string][]byte
+	mutex     sync.Mutex
+}
+
+// NewHashes returns an empty set of generated hashes
+func NewHashes() *Hashes {
+	return &Hashes{passwords: map[string][]byte{}}
+}
+
+// hash returns a new bcrypt hash of the password
+func (h *Hashes) hash(password []byte) (string, error) {
+	if len(password) > bcryptMaxPassword {
+		return "", errPasswordTooLong
+	}
+	b, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	if h != nil {
+		h.mutex.Lock()
+		h.passwords[string(b)] = password
+		h.mutex.Unlock()
+	}
+	return string(b), nil
+}
+
+// Reuse returns the value with each hash generated by h replaced by a hash
+// within the existing value that matches the same password.
+// Existing hashes are compared in the order they appear within the value.
+func (h *Hashes) Reuse(value, existing string) string {
+	if h == nil || value == existing {
+		return value
+	}
+	candidates := bcryptPattern.FindAllString(existing, -1)
+	if len(candidates) == 0 {
+		return value
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	used := make([]bool, len(candidates))
+	i := 0
+	return bcryptPattern.ReplaceAllStringFunc(value, func(hash string) string {
+		pos := i
+		i++
+		password, ok := h.passwords[hash]
+		if !ok {
+			return hash
+		}
+		if pos < len(candidates) && !used[pos] && bcrypt.CompareHashAndPassword([]byte(candidates[pos]), password) == nil {
+			used[pos] = true
+			return candidates[pos]
+		}
+		for j, c := range candidates {
+			if j != pos && !used[j] && bcrypt.CompareHashAndPassword([]byte(c), password) == nil {
+				used[j] = true
+				return c
+			}
+		}
+		return hash
+	})
+}
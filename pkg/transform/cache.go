@@ -0,0 +1,118 @@
+package transform
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/itchyny/gojq"
+)
+
+// DefaultQueryCache caches the queries and templates compiled by the engines
+var DefaultQueryCache = NewQueryCache(1024)
+
+// functionSet is a set of custom functions a query is compiled with.
+// Its name is part of the cache key.
+type functionSet struct {
+	name    string
+	options []gojq.CompilerOption
+	// defs are prepended to the function definitions of the query
+	defs []*gojq.FuncDef
+}
+
+var defaultFunctions = functionSet{name: "default", options: compilerOptions(functions), defs: preludeFuncDefs}
+
+// cacheKey identifies a compiled expression.
+// functions is the name of the jq function set or the template engine.
+type cacheKey struct {
+	functions string
+	query     string
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	compiled interface{}
+}
+
+// QueryCache is an LRU cache of compiled queries and templates
+type QueryCache struct {
+	size    int
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	hits    uint64
+	misses  uint64
+	mutex   sync.Mutex
+}
+
+func NewQueryCache(size int) *QueryCache {
+	return &QueryCache{
+		size:    size,
+		entries: map[cacheKey]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Stats returns the number of cache hits and misses
+func (c *QueryCache) Stats() (hits, misses uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.hits, c.misses
+}
+
+// compile returns the compiled jq query from the cache or compiles and adds it.
+// Queries that cannot be compiled are not cached.
+func (c *QueryCache) compile(query string, functions functionSet) (*gojq.Code, error) {
+	code, err := c.get(cacheKey{functions.name, query}, func() (interface{}, error) {
+		q, err := gojq.Parse(query)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+		}
+		if name := reservedName(reflect.ValueOf(q)); name != "" {
+			return nil, fmt.Errorf("%w: %s is reserved for internal use", ErrInvalidQuery, name)
+		}
+		q.FuncDefs = append(functions.defs[:len(functions.defs):len(functions.defs)], q.FuncDefs...)
+		code, err := gojq.Compile(q, functions.options...)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+		}
+		return code, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return code.(*gojq.Code), nil
+}
+
+// get returns the cached value for the key or compiles and adds it
+func (c *QueryCache) get(key cacheKey, compile func() (interface{}, error)) (interface{}, error) {
+	c.mutex.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.hits++
+		c.lru.MoveToFront(e)
+		c.mutex.Unlock()
+		return e.Value.(*cacheEntry).compiled, nil
+	}
+	c.misses++
+	c.mutex.Unlock()
+
+	compiled, err := compile()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.entries[key]; ok {
+		// added concurrently
+		c.lru.MoveToFront(e)
+		return e.Value.(*cacheEntry).compiled, nil
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key, compiled})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,41 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCache(t *testing.T) {
+	c := NewQueryCache(2)
+	other := functionSet{name: "other"}
+	for _, q := range []struct {
+		query     string
+		functions functionSet
+	}{
+		{".a", defaultFunctions}, // miss
+		{".a", defaultFunctions}, // hit
+		{".a", other},            // miss
+		{".b", defaultFunctions}, // miss, evicts .a (default)
+		{".a", other},            // hit
+		{".a", defaultFunctions}, // miss
+	} {
+		code, err := c.compile(q.query, q.functions)
+		require.NoError(t, err, q.query)
+		v, ok := code.RunWithContext(context.Background(), map[string]interface{}{"a": 1, "b": 2}).Next()
+		require.True(t, ok, "result")
+		require.NotNil(t, v)
+	}
+	hits, misses := c.Stats()
+	require.Equal(t, uint64(2), hits, "hits")
+	require.Equal(t, uint64(4), misses, "misses")
+	require.Equal(t, 2, c.lru.Len(), "size")
+
+	_, err := c.compile("invalid(", defaultFunctions)
+	require.True(t, errors.Is(err, ErrInvalidQuery), "parse error")
+	_, err = c.compile("undefinedfn", defaultFunctions)
+	require.True(t, errors.Is(err, ErrInvalidQuery), "compile error")
+	require.Equal(t, 2, c.lru.Len(), "invalid queries should not be cached")
+}
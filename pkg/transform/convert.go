@@ -3,82 +3,120 @@ package transform
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
+	k8sjson "k8s.io/apimachinery/pkg/util/json"
 	"sigs.k8s.io/yaml"
 )
 
-func InputMapFromStringMap(data map[string]string) map[string]interface{} {
+// InputMapFromStringMap exposes each value as string and parsed representations.
+// formats optionally maps keys to the format their value should be parsed as.
+func InputMapFromStringMap(data map[string]string, formats map[string]string) map[string]interface{} {
 	input := map[string]interface{}{}
-	if data != nil {
-		for k, v := range data {
-			val := map[string]interface{}{}
-			val["string"] = v
-			val["object"] = parseYaml([]byte(v))
-			input[k] = val
-		}
+	for k, v := range data {
+		input[k] = inputValue(k, []byte(v), formats[k])
 	}
 	return input
 }
 
-func InputMapFromBytesMap(data map[string][]byte) map[string]interface{} {
+// InputMapFromBytesMap exposes each value as string and parsed representations.
+// formats optionally maps keys to the format their value should be parsed as.
+func InputMapFromBytesMap(data map[string][]byte, formats map[string]string) map[string]interface{} {
 	input := map[string]interface{}{}
-	if data != nil {
-		for k, v := range data {
-			val := map[string]interface{}{}
-			val["string"] = string(v)
-			val["object"] = parseYaml(v)
-			input[k] = val
-		}
+	for k, v := range data {
+		input[k] = inputValue(k, v, formats[k])
 	}
 	return input
 }
 
-func BytesMapFromOutput(m map[string]interface{}) (map[string][]byte, error) {
+// InputFromObject converts an unstructured object's content into a
+// representation that can be queried (integers are represented as int).
+func InputFromObject(obj map[string]interface{}) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	return normalizeValue(obj).(map[string]interface{})
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, v := range c {
+			m[k] = normalizeValue(v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(c))
+		for i, v := range c {
+			l[i] = normalizeValue(v)
+		}
+		return l
+	case int64:
+		return int(c)
+	case int32:
+		return int(c)
+	case []map[string]interface{}:
+		l := make([]interface{}, len(c))
+		for i, v := range c {
+			l[i] = normalizeValue(v)
+		}
+		return l
+	case time.Time:
+		return c.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// ObjectFromOutput converts a query result into an unstructured object's
+// content (integers are represented as int64).
+// A string result (e.g. a rendered template) is parsed as YAML or JSON.
+func ObjectFromOutput(v interface{}) (map[string]interface{}, error) {
+	var b []byte
+	var err error
+	switch c := v.(type) {
+	case map[string]interface{}:
+		b, err = json.Marshal(v)
+	case string:
+		b, err = yaml.YAMLToJSON([]byte(c))
+	default:
+		return nil, fmt.Errorf("query returned %T but object expected", v)
+	}
+	if err != nil {
+		return nil, &ResultError{Reason: "query returned an invalid object", Err: err}
+	}
+	m := map[string]interface{}{}
+	if err = k8sjson.Unmarshal(b, &m); err != nil {
+		return nil, &ResultError{Reason: "query returned an invalid object", Err: err}
+	}
+	return m, nil
+}
+
+// BytesMapFromOutput converts query results into Secret data.
+// formats optionally maps keys to the format their value is serialized as.
+func BytesMapFromOutput(m map[string]interface{}, formats map[string]string) (map[string][]byte, error) {
 	r := map[string][]byte{}
 	for k, v := range m {
-		switch c := v.(type) {
-		case []byte:
-			r[k] = c
-		case string:
-			r[k] = []byte(c)
-		case nil:
-			r[k] = []byte{}
-		default:
-			b, err := json.Marshal(v)
-			if err != nil {
-				return nil, fmt.Errorf("marshal key %s: %w", k, err)
-			}
-			r[k] = b
+		b, err := serialize(v, formats[k])
+		if err != nil {
+			return nil, &ResultError{Reason: "cannot serialize value", Err: fmt.Errorf("key %s: %w", k, err)}
 		}
+		r[k] = b
 	}
 	return r, nil
 }
 
-func StringMapFromOutput(m map[string]interface{}) (map[string]string, error) {
+// StringMapFromOutput converts query results into ConfigMap data.
+// formats optionally maps keys to the format their value is serialized as.
+func StringMapFromOutput(m map[string]interface{}, formats map[string]string) (map[string]string, error) {
 	r := map[string]string{}
 	for k, v := range m {
-		switch c := v.(type) {
-		case []byte:
-			r[k] = string(c)
-		case string:
-			r[k] = c
-		case nil:
-			r[k] = ""
-		default:
-			b, err := json.Marshal(v)
-			if err != nil {
-				return nil, err
-			}
-			r[k] = string(b)
+		b, err := serialize(v, formats[k])
+		if err != nil {
+			return nil, &ResultError{Reason: "cannot serialize value", Err: fmt.Errorf("key %s: %w", k, err)}
 		}
+		r[k] = string(b)
 	}
 	return r, nil
 }
-
-func parseYaml(data []byte) map[string]interface{} {
-	m := map[string]interface{}{}
-	if err := yaml.Unmarshal(data, &m); err != nil {
-		return nil
-	}
-	return m
-}
@@ -27,30 +27,64 @@ var (
 		"nil": "",
 	}
 	expectedInputMap = map[string]interface{}{
-		"str": map[string]interface{}{"string": "value1", "object": map[string]interface{}(nil)},
-		"num": map[string]interface{}{"string": "7", "object": map[string]interface{}(nil)},
-		"obj": map[string]interface{}{"string": "prop: x", "object": map[string]interface{}{"prop": "x"}},
+		"str": map[string]interface{}{"string": "value1", "object": map[string]interface{}(nil), "yaml": "value1"},
+		"num": map[string]interface{}{"string": "7", "object": map[string]interface{}(nil), "yaml": float64(7)},
+		"obj": map[string]interface{}{"string": "prop: x", "object": map[string]interface{}{"prop": "x"}, "yaml": map[string]interface{}{"prop": "x"}},
 	}
 )
 
 func TestInputMapFromStringMap(t *testing.T) {
-	a := InputMapFromStringMap(testInputStringMap)
+	a := InputMapFromStringMap(testInputStringMap, nil)
 	require.Equal(t, expectedInputMap, a)
 }
 
+func TestInputFromObject(t *testing.T) {
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"port":  int64(80),
+			"ratio": 0.5,
+			"list":  []interface{}{int64(1), "str"},
+		},
+	}
+	expected := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"port":  80,
+			"ratio": 0.5,
+			"list":  []interface{}{1, "str"},
+		},
+	}
+	require.Equal(t, expected, InputFromObject(obj))
+	require.Nil(t, InputFromObject(nil))
+}
+
+func TestObjectFromOutput(t *testing.T) {
+	a, err := ObjectFromOutput(map[string]interface{}{
+		"kind": "Service",
+		"spec": map[string]interface{}{"port": 80, "ratio": 0.5},
+	})
+	require.NoError(t, err)
+	expected := map[string]interface{}{
+		"kind": "Service",
+		"spec": map[string]interface{}{"port": int64(80), "ratio": 0.5},
+	}
+	require.Equal(t, expected, a)
+	_, err = ObjectFromOutput("str")
+	require.Error(t, err, "non-object output")
+}
+
 func TestStringMapFromOutput(t *testing.T) {
-	a, err := StringMapFromOutput(testInputObjMap)
+	a, err := StringMapFromOutput(testInputObjMap, nil)
 	require.NoError(t, err)
 	require.Equal(t, expectedStringMap, a)
 }
 
 func TestInputMapFromBytesMap(t *testing.T) {
-	a := InputMapFromBytesMap(toBytes(testInputStringMap))
+	a := InputMapFromBytesMap(toBytes(testInputStringMap), nil)
 	require.Equal(t, expectedInputMap, a)
 }
 
 func TestBytesMapFromOutput(t *testing.T) {
-	a, err := BytesMapFromOutput(testInputObjMap)
+	a, err := BytesMapFromOutput(testInputObjMap, nil)
 	require.NoError(t, err)
 	require.Equal(t, toBytes(expectedStringMap), a)
 }
@@ -0,0 +1,94 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Timeout is the maximum duration of an evaluation
+const Timeout = 5 * time.Second
+
+// Engine names
+const (
+	EngineJQ       = "jq"
+	EngineTemplate = "template"
+)
+
+// ErrUnknownEngine is returned when an unsupported engine is requested
+var ErrUnknownEngine = errors.New("unknown engine")
+
+// Engine evaluates expressions against an input scope
+type Engine interface {
+	// Compile returns an error wrapping ErrInvalidQuery if the expression is invalid
+	Compile(expr string) error
+	// Run compiles and evaluates the expression. It must return when ctx is done.
+	Run(ctx context.Context, input map[string]interface{}, expr string) (interface{}, error)
+}
+
+var engines = map[string]Engine{
+	EngineJQ:       jqEngine{},
+	EngineTemplate: templateEngine{},
+}
+
+// EngineByName returns the engine with the given name.
+// An empty name selects the jq engine.
+func EngineByName(name string) (Engine, error) {
+	if name == "" {
+		name = EngineJQ
+	}
+	e, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownEngine, name)
+	}
+	return e, nil
+}
+
+// WithHashes returns the engine with its bcrypt hashes recorded in hashes.
+// Engines that do not provide the bcrypt function are returned unchanged.
+func WithHashes(engine Engine, hashes *Hashes) Engine {
+	if _, ok := engine.(jqEngine); ok {
+		return jqEngine{hashes: hashes}
+	}
+	return engine
+}
+
+// WithObserver returns the engine that reports the duration of each evaluation to observe.
+// It must be applied after WithHashes.
+func WithObserver(engine Engine, observe func(time.Duration)) Engine {
+	if observe == nil {
+		return engine
+	}
+	return &observedEngine{engine, observe}
+}
+
+type observedEngine struct {
+	Engine
+	observe func(time.Duration)
+}
+
+func (e *observedEngine) Run(ctx context.Context, input map[string]interface{}, expr string) (interface{}, error) {
+	start := time.Now()
+	defer func() { e.observe(time.Since(start)) }()
+	return e.Engine.Run(ctx, input, expr)
+}
+
+// Evaluate evaluates the expression using the given engine within Timeout.
+// Evaluation errors are returned as QueryError.
+func Evaluate(engine Engine, input map[string]interface{}, expr string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	return evaluate(ctx, engine, input, expr)
+}
+
+func evaluate(ctx context.Context, engine Engine, input map[string]interface{}, expr string) (interface{}, error) {
+	v, err := engine.Run(ctx, input, expr)
+	if err != nil {
+		if errors.Is(err, ErrInvalidQuery) {
+			return nil, err
+		}
+		return nil, &QueryError{expr, err}
+	}
+	return v, nil
+}
@@ -0,0 +1,105 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateEngine(t *testing.T) {
+	engine, err := EngineByName(EngineTemplate)
+	require.NoError(t, err)
+	input := map[string]interface{}{
+		"config": InputMapFromStringMap(map[string]string{"conf": "hosts: [a.example.org, b.example.org]", "port": "8080"}, nil),
+	}
+	for _, c := range []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"string", "listen {{ .config.port.string }};", "listen 8080;"},
+		{"range", "{{ range .config.conf.object.hosts }}server {{ . }};\n{{ end }}", "server a.example.org;\nserver b.example.org;\n"},
+		{"sprig", `{{ .config.port.string | quote }} {{ "x" | b64enc }}`, `"8080" eA==`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			v, err := Evaluate(engine, input, c.template)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, v)
+		})
+	}
+}
+
+func TestTemplateEngineErrors(t *testing.T) {
+	engine, err := EngineByName(EngineTemplate)
+	require.NoError(t, err)
+	_, err = Evaluate(engine, nil, "{{ invalid")
+	require.True(t, errors.Is(err, ErrInvalidQuery), "parse error should be ErrInvalidQuery but was %v", err)
+	_, err = Evaluate(engine, nil, `{{ env "HOME" }}`)
+	require.True(t, errors.Is(err, ErrInvalidQuery), "env function should not be available but was %v", err)
+	_, err = Evaluate(engine, nil, `{{ fail "secret" }}`)
+	var qerr *QueryError
+	require.True(t, errors.As(err, &qerr), "runtime error should be QueryError but was %v", err)
+	_, err = EngineByName("unknown")
+	require.True(t, errors.Is(err, ErrUnknownEngine), "unknown engine")
+}
+
+func TestTemplateEngineExcludedFunctions(t *testing.T) {
+	engine, err := EngineByName(EngineTemplate)
+	require.NoError(t, err)
+	for _, fn := range []string{
+		"env", "expandenv",
+		"randAlpha", "randAlphaNum", "randNumeric", "randAscii", "uuidv4", "shuffle",
+		"genPrivateKey", "genCA", "genSelfSignedCert", "genSignedCert", "htpasswd", "encryptAES",
+		"now", "date", "dateInZone", "date_in_zone", "dateModify", "date_modify", "mustDateModify",
+		"htmlDate", "htmlDateInZone", "ago", "toDate", "mustToDate", "unixEpoch", "durationRound",
+		"getHostByName",
+	} {
+		err := engine.Compile("{{ " + fn + " }}")
+		require.True(t, errors.Is(err, ErrInvalidQuery), "function %s should not be available but was %v", fn, err)
+	}
+}
+
+func TestTemplateEngineLimits(t *testing.T) {
+	engine, err := EngineByName(EngineTemplate)
+	require.NoError(t, err)
+	for _, c := range []struct {
+		name     string
+		template string
+	}{
+		{"nested ranges", `{{ range until 100000 }}{{ range until 100000 }}{{ end }}{{ end }}`},
+		{"recursive template", `{{ define "r" }}{{ template "r" . }}{{ template "r" . }}{{ end }}{{ template "r" . }}`},
+		{"output size", `{{ range until 100000 }}{{ repeat 1000 "x" }}{{ end }}`},
+		{"until", `{{ until 100000000 }}`},
+		{"repeat", `{{ repeat 100000000 "x" }}`},
+		{"indent", `{{ indent 100000000 "x" }}`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := evaluate(ctx, engine, nil, c.template)
+			require.Error(t, err)
+			require.True(t, time.Since(start) < time.Second, "evaluation should stop within the timeout")
+		})
+	}
+	v, err := Evaluate(engine, nil, `{{ range until 3 }}{{ . }}{{ end }}`)
+	require.NoError(t, err)
+	require.Equal(t, "012", v)
+}
+
+func TestWithObserver(t *testing.T) {
+	engine, err := EngineByName(EngineJQ)
+	require.NoError(t, err)
+	var durations []time.Duration
+	engine = WithObserver(WithHashes(engine, NewHashes()), func(d time.Duration) {
+		durations = append(durations, d)
+	})
+	_, err = Evaluate(engine, nil, `"a"`)
+	require.NoError(t, err)
+	_, err = Evaluate(engine, nil, `error("fail")`)
+	require.Error(t, err)
+	require.Equal(t, 2, len(durations), "observed evaluations")
+}
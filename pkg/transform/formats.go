@@ -0,0 +1,234 @@
+package transform
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"github.com/magiconair/properties"
+	"sigs.k8s.io/yaml"
+)
+
+// Input formats a value can be parsed from.
+// A parsed value is exposed using the format's name as key.
+const (
+	FormatYAML       = "yaml"
+	FormatProperties = "properties"
+	FormatEnv        = "env"
+	FormatINI        = "ini"
+	FormatTOML       = "toml"
+	FormatXML        = "xml"
+)
+
+var parsers = map[string]func([]byte) (interface{}, error){
+	FormatYAML:       parseYamlValue,
+	FormatProperties: parseProperties,
+	FormatEnv:        parseEnv,
+	FormatINI:        parseINI,
+	FormatTOML:       parseTOML,
+	FormatXML:        parseXML,
+}
+
+// formatExtensions maps file extensions to the format they are detected as
+var formatExtensions = map[string]string{
+	".properties": FormatProperties,
+	".env":        FormatEnv,
+	".ini":        FormatINI,
+	".toml":       FormatTOML,
+	".xml":        FormatXML,
+}
+
+// IsFormat returns true if the format is supported
+func IsFormat(format string) bool {
+	_, ok := parsers[format]
+	return ok
+}
+
+// detectFormat returns the declared format or the one derived from the key's extension
+func detectFormat(key, declared string) string {
+	if declared != "" {
+		return declared
+	}
+	for ext, format := range formatExtensions {
+		if strings.HasSuffix(key, ext) {
+			return format
+		}
+	}
+	return ""
+}
+
+// inputValue exposes a data value as string, its YAML representation
+// and, if a format is declared or detected, its parsed representation.
+// Values that cannot be parsed are exposed as nil.
+func inputValue(key string, v []byte, declaredFormat string) map[string]interface{} {
+	yamlValue, _ := parseYamlValue(v)
+	obj, _ := yamlValue.(map[string]interface{})
+	val := map[string]interface{}{
+		"string":   string(v),
+		"object":   obj,
+		FormatYAML: yamlValue,
+	}
+	if format := detectFormat(key, declaredFormat); format != "" && format != FormatYAML {
+		parse, ok := parsers[format]
+		if ok {
+			parsed, err := parse(v)
+			if err != nil {
+				parsed = nil
+			}
+			val[format] = parsed
+		}
+	}
+	return val
+}
+
+func parseYamlValue(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return normalizeValue(v), nil
+}
+
+func parseProperties(data []byte) (interface{}, error) {
+	l := &properties.Loader{Encoding: properties.UTF8, DisableExpansion: true}
+	p, err := l.LoadBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return stringMap(p.Map()), nil
+}
+
+func parseEnv(data []byte) (interface{}, error) {
+	m, err := godotenv.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return stringMap(m), nil
+}
+
+// parseINI parses an INI file into a map of sections.
+// Keys that are specified before the first section are added to the top level.
+func parseINI(data []byte) (interface{}, error) {
+	root := map[string]interface{}{}
+	section := root
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == ';' || line[0] == '#':
+			continue
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section", n)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			s, ok := root[name].(map[string]interface{})
+			if !ok {
+				s = map[string]interface{}{}
+				root[name] = s
+			}
+			section = s
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key=value", n)
+		}
+		section[strings.TrimSpace(line[:i])] = unquote(strings.TrimSpace(line[i+1:]))
+	}
+	return root, scanner.Err()
+}
+
+func unquote(s string) string {
+	if len(s) > 1 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func parseTOML(data []byte) (interface{}, error) {
+	m := map[string]interface{}{}
+	if _, err := toml.Decode(string(data), &m); err != nil {
+		return nil, err
+	}
+	return normalizeValue(m), nil
+}
+
+// parseXML converts an XML document into a map.
+// Attributes are exposed with an @ prefix, text content as #text.
+// An element that contains only text is exposed as string,
+// repeated elements are exposed as list.
+func parseXML(data []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("no XML root element found")
+			}
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			v, err := parseXMLElement(dec, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: v}, nil
+		}
+	}
+}
+
+func parseXMLElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	m := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		m["@"+attr.Name.Local] = attr.Value
+	}
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := parseXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := m[name].(type) {
+			case nil:
+				m[name] = child
+			case []interface{}:
+				m[name] = append(existing, child)
+			default:
+				m[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m["#text"] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+func stringMap(m map[string]string) map[string]interface{} {
+	r := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
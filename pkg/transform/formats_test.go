@@ -0,0 +1,50 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputFormats(t *testing.T) {
+	data := map[string]string{
+		"list":           "- a\n- b",
+		"app.properties": "# comment\nserver.port=8080\ngreeting = hello ${name}\n",
+		".env":           "export USER=admin\nPASSWORD='secret'\n",
+		"app.ini":        "global=1\n; comment\n[db]\nhost = db.example.org\nuser: \"admin\"\n",
+		"app.toml":       "title = \"x\"\n[server]\nport = 8080\n[[users]]\nname = \"a\"\n",
+		"app.xml":        `<config version="1"><host>a</host><host>b</host><db name="x">text</db></config>`,
+		"declared":       "key=value",
+		"invalid.toml":   "=",
+	}
+	input := InputMapFromStringMap(data, map[string]string{"declared": FormatProperties})
+	for _, c := range []struct {
+		key      string
+		format   string
+		expected interface{}
+	}{
+		{"list", FormatYAML, []interface{}{"a", "b"}},
+		{"app.properties", FormatProperties, map[string]interface{}{"server.port": "8080", "greeting": "hello ${name}"}},
+		{".env", FormatEnv, map[string]interface{}{"USER": "admin", "PASSWORD": "secret"}},
+		{"app.ini", FormatINI, map[string]interface{}{"global": "1", "db": map[string]interface{}{"host": "db.example.org", "user": "admin"}}},
+		{"app.toml", FormatTOML, map[string]interface{}{
+			"title":  "x",
+			"server": map[string]interface{}{"port": 8080},
+			"users":  []interface{}{map[string]interface{}{"name": "a"}},
+		}},
+		{"app.xml", FormatXML, map[string]interface{}{"config": map[string]interface{}{
+			"@version": "1",
+			"host":     []interface{}{"a", "b"},
+			"db":       map[string]interface{}{"@name": "x", "#text": "text"},
+		}}},
+		{"declared", FormatProperties, map[string]interface{}{"key": "value"}},
+		{"invalid.toml", FormatTOML, nil},
+	} {
+		t.Run(c.key, func(t *testing.T) {
+			v := input[c.key].(map[string]interface{})
+			require.Equal(t, data[c.key], v["string"], "string")
+			require.Contains(t, v, c.format)
+			require.Equal(t, c.expected, v[c.format])
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// function is a custom jq function
+type function struct {
+	name     string
+	minarity int
+	maxarity int
+	fn       func(interface{}, []interface{}) interface{}
+}
+
+// functions lists the custom jq functions that are available to all queries
+var functions = []function{
+	{reservedPrefix + "bcrypt", 1, 1, funcBcrypt},
+	{reservedPrefix + "htpasswd", 3, 3, funcHtpasswd},
+	{"sha256", 0, 0, funcSha256},
+	{"hex", 0, 0, funcHex},
+	{"urlencode", 0, 0, funcURLEncode},
+	{"pem_decode", 0, 0, funcPEMDecode},
+	{"pem_split", 0, 0, funcPEMSplit},
+	{"pem_join", 0, 0, funcPEMJoin},
+	{"x509_decode", 0, 0, funcX509Decode},
+	{"private_key_decode", 0, 0, funcPrivateKeyDecode},
+}
+
+// reservedPrefix is the name prefix of the functions and variables used by the prelude.
+// Queries must not refer to them directly.
+const reservedPrefix = "_ktransform_"
+
+// varHashes is the variable that holds the *Hashes a query records its bcrypt hashes in
+const varHashes = "$" + reservedPrefix + "hashes"
+
+// prelude defines the functions that pass variables to custom functions
+const prelude = `
+def bcrypt: _ktransform_bcrypt($_ktransform_hashes);
+def htpasswd($user; $password): _ktransform_htpasswd($user; $password; $_ktransform_hashes);
+`
+
+var preludeFuncDefs = func() []*gojq.FuncDef {
+	q, err := gojq.Parse(prelude)
+	if err != nil {
+		panic(err)
+	}
+	return q.FuncDefs
+}()
+
+// reservedName returns the first function or variable name within the parsed query
+// that starts with reservedPrefix or an empty string if there is none
+func reservedName(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return ""
+		}
+		if v.Type() == reflect.TypeOf(&gojq.Func{}) {
+			name := v.Elem().FieldByName("Name").String()
+			if strings.HasPrefix(strings.TrimPrefix(name, "$"), reservedPrefix) {
+				return name
+			}
+		}
+		return reservedName(v.Elem())
+	case reflect.Interface:
+		return reservedName(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if name := reservedName(v.Field(i)); name != "" {
+				return name
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if name := reservedName(v.Index(i)); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func compilerOptions(functions []function) []gojq.CompilerOption {
+	opts := make([]gojq.CompilerOption, len(functions), len(functions)+1)
+	for i, f := range functions {
+		opts[i] = gojq.WithFunction(f.name, f.minarity, f.maxarity, f.fn)
+	}
+	return append(opts, gojq.WithVariables([]string{varHashes}))
+}
+
+type funcTypeError struct {
+	name string
+	v    interface{}
+}
+
+func (e *funcTypeError) Error() string {
+	return fmt.Sprintf("%s cannot be applied to %T", e.name, e.v)
+}
+
+// stringFunc returns a function that is applied to a string input
+func stringFunc(name string, fn func(string) interface{}) func(interface{}, []interface{}) interface{} {
+	return func(v interface{}, _ []interface{}) interface{} {
+		s, ok := v.(string)
+		if !ok {
+			return &funcTypeError{name, v}
+		}
+		return fn(s)
+	}
+}
+
+// funcBcrypt returns a bcrypt hash of the input string
+// and records it within the *Hashes passed as argument
+func funcBcrypt(v interface{}, args []interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"bcrypt", v}
+	}
+	hashes, _ := args[0].(*Hashes)
+	h, err := hashes.hash([]byte(s))
+	if err != nil {
+		return err
+	}
+	return h
+}
+
+// funcHtpasswd returns an htpasswd entry with a bcrypt hashed password
+func funcHtpasswd(_ interface{}, args []interface{}) interface{} {
+	user, ok := args[0].(string)
+	if !ok {
+		return &funcTypeError{"htpasswd", args[0]}
+	}
+	if user == "" || strings.Contains(user, ":") {
+		return fmt.Errorf("htpasswd: invalid user name %q", user)
+	}
+	pw, ok := args[1].(string)
+	if !ok {
+		return &funcTypeError{"htpasswd", args[1]}
+	}
+	hashes, _ := args[2].(*Hashes)
+	h, err := hashes.hash([]byte(pw))
+	if err != nil {
+		return err
+	}
+	return user + ":" + h
+}
+
+var funcSha256 = stringFunc("sha256", func(s string) interface{} {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+})
+
+var funcHex = stringFunc("hex", func(s string) interface{} {
+	return hex.EncodeToString([]byte(s))
+})
+
+var funcURLEncode = stringFunc("urlencode", func(s string) interface{} {
+	return url.QueryEscape(s)
+})
+
+// funcPEMDecode returns the blocks of a PEM string.
+// The bytes of a block are base64 encoded.
+var funcPEMDecode = stringFunc("pem_decode", func(s string) interface{} {
+	blocks := []interface{}{}
+	for _, block := range pemBlocks(s) {
+		headers := map[string]interface{}{}
+		for k, v := range block.Headers {
+			headers[k] = v
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type":    block.Type,
+			"headers": headers,
+			"bytes":   base64.StdEncoding.EncodeToString(block.Bytes),
+		})
+	}
+	return blocks
+})
@@ -0,0 +1,84 @@
+package transform
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPEM = `-----BEGIN TEST-----
+Proc-Type: 4,ENCRYPTED
+
+c29tZSBieXRlcw==
+-----END TEST-----
+-----BEGIN OTHER-----
+b3RoZXI=
+-----END OTHER-----
+`
+
+func TestFunctions(t *testing.T) {
+	input := map[string]interface{}{"str": "some value", "num": 7, "pem": testPEM}
+	for _, c := range []struct {
+		name     string
+		query    string
+		expected interface{}
+		valid    bool
+	}{
+		{"sha256", ".str | sha256", "ab3d07f3169ccbd0ed6c4b45de21519f9f938c72d24124998aab949ce83bb51b", true},
+		{"sha256 non-string", ".num | sha256", nil, false},
+		{"hex", ".str | hex", "736f6d652076616c7565", true},
+		{"urlencode", `"a b&c=d/é" | urlencode`, "a+b%26c%3Dd%2F%C3%A9", true},
+		{"urlencode interpolation", `"https://example.org/?q=\(.str | urlencode)"`, "https://example.org/?q=some+value", true},
+		{"urlencode non-string", ".num | urlencode", nil, false},
+		{"format builtin", `.str | format("base64")`, "c29tZSB2YWx1ZQ==", true},
+		{"format unknown", `.str | format("urlencode")`, nil, false},
+		{"reserved function", `"pw" | _ktransform_bcrypt(null)`, nil, false},
+		{"reserved function within definition", `def f: _ktransform_htpasswd("usr"; "pw"; null); f`, nil, false},
+		{"reserved variable", `$_ktransform_hashes`, nil, false},
+		{"pem_decode", ".pem | pem_decode", []interface{}{
+			map[string]interface{}{"type": "TEST", "headers": map[string]interface{}{"Proc-Type": "4,ENCRYPTED"}, "bytes": "c29tZSBieXRlcw=="},
+			map[string]interface{}{"type": "OTHER", "headers": map[string]interface{}{}, "bytes": "b3RoZXI="},
+		}, true},
+		{"pem_decode bytes", ".pem | pem_decode | .[1].bytes | @base64d", "other", true},
+		{"pem_decode no PEM", ".str | pem_decode", []interface{}{}, true},
+		{"htpasswd invalid user", `htpasswd("a:b"; "pw")`, nil, false},
+		{"bcrypt too long", `"x" * 73 | bcrypt`, nil, false},
+	} {
+		testQuery(t, c.name, input, c.query, c.expected, c.valid)
+	}
+}
+
+func TestFunctionBcrypt(t *testing.T) {
+	h1, err := Query(context.Background(), nil, `"secret" | bcrypt`)
+	require.NoError(t, err)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(h1.(string)), []byte("secret")), "compare hash")
+	h2, err := Query(context.Background(), nil, `"secret" | bcrypt`)
+	require.NoError(t, err)
+	require.NotEqual(t, h1, h2, "hash should be salted randomly")
+
+	entry, err := Query(context.Background(), nil, `htpasswd("usr"; "secret")`)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(entry.(string), "usr:$2a$10$"), "htpasswd entry %q", entry)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(entry.(string)[4:]), []byte("secret")), "compare htpasswd hash")
+}
+
+func TestHashesReuse(t *testing.T) {
+	hashes := NewHashes()
+	engine := WithHashes(jqEngine{}, hashes)
+	existing, err := Evaluate(engine, nil, `[htpasswd("a"; "pw-a"), htpasswd("b"; "pw-b")] | join("\n")`)
+	require.NoError(t, err)
+	v, err := Evaluate(engine, nil, `[htpasswd("b"; "pw-b"), htpasswd("a"; "pw-a"), htpasswd("c"; "pw-c")] | join("\n")`)
+	require.NoError(t, err)
+	lines := strings.Split(existing.(string), "\n")
+	reused := strings.Split(hashes.Reuse(v.(string), existing.(string)), "\n")
+	require.Equal(t, []string{lines[1], lines[0]}, reused[:2], "should reuse existing hashes")
+	require.Equal(t, strings.Split(v.(string), "\n")[2], reused[2], "should keep new hash")
+
+	v, err = Evaluate(engine, nil, `"changed" | bcrypt`)
+	require.NoError(t, err)
+	require.Equal(t, v, hashes.Reuse(v.(string), lines[0][2:]), "should not reuse hash of other password")
+	require.Equal(t, "other", hashes.Reuse("other", lines[0][2:]), "should keep value without generated hash")
+}
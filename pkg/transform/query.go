@@ -2,23 +2,81 @@ package transform
 
 import (
 	"context"
+	"errors"
 	"fmt"
-
-	"github.com/itchyny/gojq"
 )
 
+// ErrInvalidQuery is returned when a query cannot be parsed or compiled
+var ErrInvalidQuery = errors.New("invalid query")
+
+// QueryError is returned when a query fails during evaluation.
+// Its message may contain input values.
+type QueryError struct {
+	Query string
+	Err   error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query %s: %s", e.Query, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Redacted returns the error message without the evaluation error
+func (e *QueryError) Redacted() string {
+	return fmt.Sprintf("query %s failed", e.Query)
+}
+
+// ResultError is returned when a query result cannot be converted into an output.
+// Its message may contain (secret) values of the result.
+type ResultError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ResultError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ResultError) Unwrap() error {
+	return e.Err
+}
+
+// Redacted returns the reason only
+func (e *ResultError) Redacted() string {
+	return e.Reason
+}
+
+// Query evaluates a jq query
 func Query(ctx context.Context, input map[string]interface{}, query string) (interface{}, error) {
-	q, err := gojq.Parse(query)
+	return evaluate(ctx, jqEngine{}, input, query)
+}
+
+// jqEngine evaluates jq queries and returns their first result.
+// The bcrypt hashes generated by a query are recorded in hashes.
+type jqEngine struct {
+	hashes *Hashes
+}
+
+func (jqEngine) Compile(query string) error {
+	_, err := DefaultQueryCache.compile(query, defaultFunctions)
+	return err
+}
+
+func (e jqEngine) Run(ctx context.Context, input map[string]interface{}, query string) (interface{}, error) {
+	code, err := DefaultQueryCache.compile(query, defaultFunctions)
 	if err != nil {
 		return nil, err
 	}
-	iter := q.RunWithContext(ctx, input)
+	iter := code.RunWithContext(ctx, input, e.hashes)
 	v, ok := iter.Next()
 	if !ok {
-		return nil, fmt.Errorf("query did not return anything: %s", query)
+		return nil, errors.New("query did not return anything")
 	}
 	if err, ok := v.(error); ok {
-		return nil, fmt.Errorf("query %s: %w", query, err)
+		return nil, err
 	}
 	return v, nil
 }
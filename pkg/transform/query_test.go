@@ -3,6 +3,7 @@ package transform
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -35,6 +36,16 @@ func TestQuery(t *testing.T) {
 	}
 }
 
+func TestQueryInvalid(t *testing.T) {
+	_, err := Query(context.Background(), nil, "invalid(")
+	require.True(t, errors.Is(err, ErrInvalidQuery), "parse error should be ErrInvalidQuery but was %v", err)
+	_, err = Query(context.Background(), nil, "error(\"fail\")")
+	require.Error(t, err, "runtime error")
+	require.False(t, errors.Is(err, ErrInvalidQuery), "runtime error should not be ErrInvalidQuery")
+	var qerr *QueryError
+	require.True(t, errors.As(err, &qerr), "runtime error should be QueryError but was %v", err)
+}
+
 func testQuery(t *testing.T, name string, input map[string]interface{}, query string, expected interface{}, valid bool) {
 	t.Run(name, func(t *testing.T) {
 		output, err := Query(context.Background(), input, query)
@@ -0,0 +1,262 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats a query result can be serialized as.
+// All formats order keys alphabetically in order to produce stable output.
+const (
+	FormatJSON       = "json"
+	FormatPrettyJSON = "prettyjson"
+)
+
+var serializers = map[string]func(interface{}) ([]byte, error){
+	FormatJSON:       json.Marshal,
+	FormatPrettyJSON: marshalPrettyJSON,
+	FormatYAML:       yaml.Marshal,
+	FormatTOML:       marshalTOML,
+	FormatProperties: marshalProperties,
+	FormatEnv:        marshalEnv,
+	FormatINI:        marshalINI,
+}
+
+// IsOutputFormat returns true if query results can be serialized using the format
+func IsOutputFormat(format string) bool {
+	_, ok := serializers[format]
+	return ok
+}
+
+// serialize converts a query result into the given format.
+// Without format strings are returned as is while other values are JSON encoded.
+func serialize(v interface{}, format string) ([]byte, error) {
+	if format == "" {
+		switch c := v.(type) {
+		case []byte:
+			return c, nil
+		case string:
+			return []byte(c), nil
+		case nil:
+			return []byte{}, nil
+		}
+		return json.Marshal(v)
+	}
+	marshal, ok := serializers[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+	b, err := marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", format, err)
+	}
+	return b, nil
+}
+
+func marshalPrettyJSON(v interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func marshalTOML(v interface{}) ([]byte, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("object expected but query returned %T", v)
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalProperties writes the flattened object as Java properties.
+// Nested keys are joined with a dot, list items are addressed by index.
+func marshalProperties(v interface{}) ([]byte, error) {
+	if _, ok := v.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("object expected but query returned %T", v)
+	}
+	flat := map[string]string{}
+	if err := flatten("", v, flat); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, k := range sortedStringKeys(flat) {
+		buf.WriteString(escapeProperty(k, true))
+		buf.WriteByte('=')
+		buf.WriteString(escapeProperty(flat[k], false))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalEnv writes an object of scalar values as dotenv file
+func marshalEnv(v interface{}) ([]byte, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("object expected but query returned %T", v)
+	}
+	var buf bytes.Buffer
+	for _, k := range sortedKeys(m) {
+		s, err := scalarString(m[k])
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k, err)
+		}
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(quoteEnv(s))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalINI writes the scalar values of an object as global keys
+// and its nested objects as sections
+func marshalINI(v interface{}) ([]byte, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("object expected but query returned %T", v)
+	}
+	var buf bytes.Buffer
+	sections := []string{}
+	for _, k := range sortedKeys(m) {
+		if _, ok := m[k].(map[string]interface{}); ok {
+			sections = append(sections, k)
+			continue
+		}
+		if err := writeINIEntry(&buf, "", k, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sections {
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(&buf, "[%s]\n", name)
+		section := m[name].(map[string]interface{})
+		for _, k := range sortedKeys(section) {
+			if err := writeINIEntry(&buf, name, k, section[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func writeINIEntry(buf *bytes.Buffer, section, key string, v interface{}) error {
+	s, err := scalarString(v)
+	if err != nil {
+		return fmt.Errorf("key %s: %w", joinKey(section, key), err)
+	}
+	if strings.ContainsAny(s, "\n;#") || strings.TrimSpace(s) != s {
+		s = strconv.Quote(s)
+	}
+	fmt.Fprintf(buf, "%s = %s\n", key, s)
+	return nil
+}
+
+func flatten(prefix string, v interface{}, flat map[string]string) error {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		for k, item := range c {
+			if err := flatten(joinKey(prefix, k), item, flat); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range c {
+			if err := flatten(joinKey(prefix, strconv.Itoa(i)), item, flat); err != nil {
+				return err
+			}
+		}
+	default:
+		s, err := scalarString(v)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", prefix, err)
+		}
+		flat[prefix] = s
+	}
+	return nil
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// scalarString returns the string representation of a string, number, boolean or null
+func scalarString(v interface{}) (string, error) {
+	switch c := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return c, nil
+	case bool, int, int64, float64:
+		b, err := json.Marshal(c)
+		return string(b), err
+	}
+	return "", fmt.Errorf("scalar value expected but found %T", v)
+}
+
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case isKey && strings.ContainsRune("=: #!", r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case !isKey && i == 0 && (r == ' ' || r == '#' || r == '!'):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func quoteEnv(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\r\"'\\$#`=") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, `$`, `\$`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
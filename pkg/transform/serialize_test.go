@@ -0,0 +1,61 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringMapFromOutputFormats(t *testing.T) {
+	v := map[string]interface{}{
+		"name":   "my app",
+		"port":   8080,
+		"debug":  false,
+		"db":     map[string]interface{}{"host": "db.example.org", "user": "admin"},
+		"labels": []interface{}{"a", "b"},
+	}
+	env := map[string]interface{}{"USER": "admin", "PASSWORD": `p"w$`, "EMPTY": nil}
+	output := map[string]interface{}{
+		"default":    v,
+		"json":       "str",
+		"prettyjson": map[string]interface{}{"b": 1, "a": []interface{}{true}},
+		"yaml":       v,
+		"toml":       map[string]interface{}{"title": "x", "db": map[string]interface{}{"port": 5432}},
+		"properties": v,
+		"env":        env,
+		"ini":        map[string]interface{}{"global": 1, "db": map[string]interface{}{"host": "db.example.org", "comment": "a;b"}},
+	}
+	formats := map[string]string{
+		"json":       FormatJSON,
+		"prettyjson": FormatPrettyJSON,
+		"yaml":       FormatYAML,
+		"toml":       FormatTOML,
+		"properties": FormatProperties,
+		"env":        FormatEnv,
+		"ini":        FormatINI,
+	}
+	expected := map[string]string{
+		"default":    `{"db":{"host":"db.example.org","user":"admin"},"debug":false,"labels":["a","b"],"name":"my app","port":8080}`,
+		"json":       `"str"`,
+		"prettyjson": "{\n  \"a\": [\n    true\n  ],\n  \"b\": 1\n}\n",
+		"yaml":       "db:\n  host: db.example.org\n  user: admin\ndebug: false\nlabels:\n- a\n- b\nname: my app\nport: 8080\n",
+		"toml":       "title = \"x\"\n\n[db]\n  port = 5432\n",
+		"properties": "db.host=db.example.org\ndb.user=admin\ndebug=false\nlabels.0=a\nlabels.1=b\nname=my app\nport=8080\n",
+		"env":        "EMPTY=\"\"\nPASSWORD=\"p\\\"w\\$\"\nUSER=admin\n",
+		"ini":        "global = 1\n\n[db]\ncomment = \"a;b\"\nhost = db.example.org\n",
+	}
+	for i := 0; i < 3; i++ {
+		a, err := StringMapFromOutput(output, formats)
+		require.NoError(t, err)
+		require.Equal(t, expected, a)
+	}
+
+	for format, v := range map[string]interface{}{
+		FormatEnv:        map[string]interface{}{"nested": map[string]interface{}{}},
+		FormatProperties: "str",
+		FormatINI:        map[string]interface{}{"s": map[string]interface{}{"nested": map[string]interface{}{}}},
+	} {
+		_, err := StringMapFromOutput(map[string]interface{}{"k": v}, map[string]string{"k": format})
+		require.Error(t, err, format)
+	}
+}
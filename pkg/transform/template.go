@@ -0,0 +1,224 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+const (
+	// maxTemplateOutput is the maximum size of a rendered template
+	maxTemplateOutput = 1 << 20
+	// maxTemplateCount is the maximum count a sprig function that builds a list or string accepts
+	maxTemplateCount = 100000
+	// funcTick is called on every range iteration and template call
+	// in order to stop the evaluation when its context is done
+	funcTick = "__tick"
+)
+
+var errTemplateOutputSize = fmt.Errorf("template output exceeds %d bytes", maxTemplateOutput)
+
+// excludedTemplateFunctions are the sprig functions that are not repeatable or have side effects
+// in addition to those that sprig considers non-hermetic (env, random, date and network functions).
+// Since outputs are transformed on every reconciliation they would change each time.
+var excludedTemplateFunctions = []string{
+	"ago",
+	"durationRound",
+	"mustDateModify",
+	"toDate",
+	"mustToDate",
+	"unixEpoch",
+	"shuffle",
+	"htpasswd",
+	"encryptAES",
+	"genPrivateKey",
+	"genCA",
+	"genSelfSignedCert",
+	"genSignedCert",
+}
+
+// templateFunctions are the repeatable sprig functions.
+// Functions that allocate a list or string of a given size are limited.
+var templateFunctions = func() template.FuncMap {
+	m := sprig.HermeticTxtFuncMap()
+	for _, name := range excludedTemplateFunctions {
+		delete(m, name)
+	}
+	limitFunctions(m)
+	return m
+}()
+
+func limitFunctions(m template.FuncMap) {
+	until := m["until"].(func(int) []int)
+	m["until"] = func(count int) ([]int, error) {
+		if err := checkCount(rangeLen(0, count, 1)); err != nil {
+			return nil, err
+		}
+		return until(count), nil
+	}
+	untilStep := m["untilStep"].(func(int, int, int) []int)
+	m["untilStep"] = func(start, stop, step int) ([]int, error) {
+		if err := checkCount(rangeLen(start, stop, step)); err != nil {
+			return nil, err
+		}
+		return untilStep(start, stop, step), nil
+	}
+	seq := m["seq"].(func(...int) string)
+	m["seq"] = func(params ...int) (string, error) {
+		first, step, last := 1, 1, 0
+		switch len(params) {
+		case 1:
+			last = params[0]
+		case 2:
+			first, last = params[0], params[1]
+		case 3:
+			first, step, last = params[0], params[1], params[2]
+		}
+		if err := checkCount(rangeLen(first, last, step)); err != nil {
+			return "", err
+		}
+		return seq(params...), nil
+	}
+	repeat := m["repeat"].(func(int, string) string)
+	m["repeat"] = func(count int, str string) (string, error) {
+		if count > 0 && len(str) > maxTemplateOutput/count {
+			return "", errTemplateOutputSize
+		}
+		return repeat(count, str), nil
+	}
+	for _, name := range []string{"indent", "nindent"} {
+		indent := m[name].(func(int, string) string)
+		m[name] = func(spaces int, v string) (string, error) {
+			if err := checkCount(spaces); err != nil {
+				return "", err
+			}
+			return indent(spaces, v), nil
+		}
+	}
+}
+
+func rangeLen(start, stop, step int) int {
+	if step == 0 {
+		return 0
+	}
+	n := (stop - start) / step
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func checkCount(count int) error {
+	if count > maxTemplateCount {
+		return fmt.Errorf("count %d exceeds %d", count, maxTemplateCount)
+	}
+	return nil
+}
+
+// templateEngine renders Go text/templates with sprig functions into a string.
+// The evaluation is stopped when the context is done on every range iteration,
+// template call and output write.
+type templateEngine struct{}
+
+func (templateEngine) Compile(expr string) error {
+	_, err := compileTemplate(expr)
+	return err
+}
+
+func (templateEngine) Run(ctx context.Context, input map[string]interface{}, expr string) (interface{}, error) {
+	tpl, err := compileTemplate(expr)
+	if err != nil {
+		return nil, err
+	}
+	if tpl, err = tpl.Clone(); err != nil {
+		return nil, err
+	}
+	tpl.Funcs(template.FuncMap{funcTick: func() (string, error) {
+		return "", ctx.Err()
+	}})
+	w := &templateWriter{ctx: ctx}
+	if err = tpl.Execute(w, input); err != nil {
+		return nil, err
+	}
+	return w.buf.String(), nil
+}
+
+// templateWriter limits the output size and fails when the context is done
+type templateWriter struct {
+	ctx context.Context
+	buf bytes.Buffer
+}
+
+func (w *templateWriter) Write(b []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.buf.Len()+len(b) > maxTemplateOutput {
+		return 0, errTemplateOutputSize
+	}
+	return w.buf.Write(b)
+}
+
+func compileTemplate(expr string) (*template.Template, error) {
+	tpl, err := DefaultQueryCache.get(cacheKey{EngineTemplate, expr}, func() (interface{}, error) {
+		tpl, err := template.New("transformation").Funcs(templateFunctions).Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+		}
+		for _, t := range tpl.Templates() {
+			if t.Tree == nil || t.Tree.Root == nil {
+				continue
+			}
+			if t.Name() != tpl.Name() {
+				// defined templates may be called recursively
+				prependNode(t.Tree.Root, tickNode)
+			}
+			addTicks(t.Tree.Root)
+		}
+		return tpl, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tpl.(*template.Template), nil
+}
+
+// tickNode is the action that calls the tick function
+var tickNode = func() parse.Node {
+	trees, err := parse.Parse("tick", "{{"+funcTick+"}}", "", "", map[string]interface{}{funcTick: fmt.Sprint})
+	if err != nil {
+		panic(err)
+	}
+	return trees["tick"].Root.Nodes[0]
+}()
+
+// addTicks prepends the tick node to the body of every range node within the list
+func addTicks(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, n := range list.Nodes {
+		switch c := n.(type) {
+		case *parse.RangeNode:
+			addTicks(c.List)
+			addTicks(c.ElseList)
+			prependNode(c.List, tickNode)
+		case *parse.IfNode:
+			addTicks(c.List)
+			addTicks(c.ElseList)
+		case *parse.WithNode:
+			addTicks(c.List)
+			addTicks(c.ElseList)
+		}
+	}
+}
+
+func prependNode(list *parse.ListNode, n parse.Node) {
+	if list != nil {
+		list.Nodes = append([]parse.Node{n}, list.Nodes...)
+	}
+}
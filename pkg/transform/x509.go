@@ -0,0 +1,188 @@
+package transform
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// funcX509Decode returns the certificates within a PEM string.
+// Blocks of other types are ignored.
+var funcX509Decode = stringFunc("x509_decode", func(s string) interface{} {
+	certs := []interface{}{}
+	for _, block := range pemBlocks(s) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("x509_decode: %w", err)
+		}
+		certs = append(certs, certificateToMap(cert, block))
+	}
+	return certs
+})
+
+// funcPrivateKeyDecode returns the private keys within a PEM string.
+// Since the key material itself is not exposed the result can be used
+// to verify that a key matches a certificate.
+var funcPrivateKeyDecode = stringFunc("private_key_decode", func(s string) interface{} {
+	keys := []interface{}{}
+	for _, block := range pemBlocks(s) {
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("private_key_decode: %s: %w", block.Type, err)
+		}
+		m, err := privateKeyToMap(key)
+		if err != nil {
+			return fmt.Errorf("private_key_decode: %w", err)
+		}
+		keys = append(keys, m)
+	}
+	return keys
+})
+
+// funcPEMSplit returns the PEM blocks of a bundle as separate strings
+var funcPEMSplit = stringFunc("pem_split", func(s string) interface{} {
+	blocks := pemBlocks(s)
+	l := make([]interface{}, len(blocks))
+	for i, block := range blocks {
+		l[i] = string(pem.EncodeToMemory(block))
+	}
+	return l
+})
+
+// funcPEMJoin concatenates an array of PEM strings to a bundle.
+// Duplicate blocks are omitted.
+func funcPEMJoin(v interface{}, _ []interface{}) interface{} {
+	l, ok := v.([]interface{})
+	if !ok {
+		return &funcTypeError{"pem_join", v}
+	}
+	var b strings.Builder
+	seen := map[string]struct{}{}
+	for _, item := range l {
+		s, ok := item.(string)
+		if !ok {
+			return &funcTypeError{"pem_join", item}
+		}
+		for _, block := range pemBlocks(s) {
+			encoded := string(pem.EncodeToMemory(block))
+			if _, ok := seen[encoded]; ok {
+				continue
+			}
+			seen[encoded] = struct{}{}
+			b.WriteString(encoded)
+		}
+	}
+	return b.String()
+}
+
+func pemBlocks(s string) (blocks []*pem.Block) {
+	rest := []byte(s)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+func certificateToMap(cert *x509.Certificate, block *pem.Block) map[string]interface{} {
+	fingerprint := sha256.Sum256(cert.Raw)
+	pubFingerprint := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	ips := make([]interface{}, len(cert.IPAddresses))
+	for i, ip := range cert.IPAddresses {
+		ips[i] = ip.String()
+	}
+	uris := make([]interface{}, len(cert.URIs))
+	for i, u := range cert.URIs {
+		uris[i] = u.String()
+	}
+	return map[string]interface{}{
+		"subject":                    nameToMap(cert.Subject),
+		"issuer":                     nameToMap(cert.Issuer),
+		"serialNumber":               cert.SerialNumber.String(),
+		"notBefore":                  cert.NotBefore.UTC().Format(time.RFC3339),
+		"notAfter":                   cert.NotAfter.UTC().Format(time.RFC3339),
+		"dnsNames":                   stringsToList(cert.DNSNames),
+		"ipAddresses":                ips,
+		"emailAddresses":             stringsToList(cert.EmailAddresses),
+		"uris":                       uris,
+		"isCA":                       cert.IsCA,
+		"signatureAlgorithm":         cert.SignatureAlgorithm.String(),
+		"publicKeyAlgorithm":         cert.PublicKeyAlgorithm.String(),
+		"fingerprintSHA256":          hex.EncodeToString(fingerprint[:]),
+		"publicKeyFingerprintSHA256": hex.EncodeToString(pubFingerprint[:]),
+		"pem":                        string(pem.EncodeToMemory(block)),
+	}
+}
+
+func nameToMap(n pkix.Name) map[string]interface{} {
+	return map[string]interface{}{
+		"commonName":         n.CommonName,
+		"organization":       stringsToList(n.Organization),
+		"organizationalUnit": stringsToList(n.OrganizationalUnit),
+		"country":            stringsToList(n.Country),
+		"locality":           stringsToList(n.Locality),
+		"province":           stringsToList(n.Province),
+		"string":             n.String(),
+	}
+}
+
+func privateKeyToMap(key interface{}) (map[string]interface{}, error) {
+	var pub interface{}
+	m := map[string]interface{}{}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		m["type"] = "RSA"
+		m["bits"] = k.N.BitLen()
+		pub = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		m["type"] = "ECDSA"
+		m["curve"] = k.Curve.Params().Name
+		m["bits"] = k.Curve.Params().BitSize
+		pub = &k.PublicKey
+	case ed25519.PrivateKey:
+		m["type"] = "Ed25519"
+		pub = k.Public()
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(der)
+	m["publicKeyFingerprintSHA256"] = hex.EncodeToString(fingerprint[:])
+	return m, nil
+}
+
+func stringsToList(l []string) []interface{} {
+	r := make([]interface{}, len(l))
+	for i, s := range l {
+		r[i] = s
+	}
+	return r
+}
@@ -0,0 +1,72 @@
+package transform
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificate(t *testing.T, cn string, serial int64) (certPEM, keyPEM string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"example"}},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		DNSNames:     []string{cn},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return
+}
+
+func TestX509Functions(t *testing.T) {
+	cert1, key1 := testCertificate(t, "a.example.org", 1)
+	cert2, _ := testCertificate(t, "b.example.org", 2)
+	input := map[string]interface{}{
+		"crt":    cert1 + cert2,
+		"key":    key1,
+		"certs":  []interface{}{cert1, cert2, cert1},
+		"nopem":  "invalid",
+		"badcrt": "-----BEGIN CERTIFICATE-----\naW52YWxpZA==\n-----END CERTIFICATE-----\n",
+	}
+	for _, c := range []struct {
+		name     string
+		query    string
+		expected interface{}
+		valid    bool
+	}{
+		{"x509_decode count", ".crt | x509_decode | length", 2, true},
+		{"x509_decode subject", ".crt | x509_decode | map(.subject.commonName)", []interface{}{"a.example.org", "b.example.org"}, true},
+		{"x509_decode organization", ".crt | x509_decode | .[0].subject.organization", []interface{}{"example"}, true},
+		{"x509_decode issuer", ".crt | x509_decode | .[0].issuer.commonName", "a.example.org", true},
+		{"x509_decode SANs", ".crt | x509_decode | .[0] | .dnsNames + .ipAddresses", []interface{}{"a.example.org", "10.0.0.1"}, true},
+		{"x509_decode notAfter", ".crt | x509_decode | .[0].notAfter", "2030-01-01T00:00:00Z", true},
+		{"x509_decode serial", ".crt | x509_decode | .[1].serialNumber", "2", true},
+		{"x509_decode pem", ".crt | x509_decode | .[1].pem", cert2, true},
+		{"x509_decode invalid", ".badcrt | x509_decode", nil, false},
+		{"x509_decode no PEM", ".nopem | x509_decode", []interface{}{}, true},
+		{"private_key_decode", ".key | private_key_decode | .[0] | {type, curve, bits}", map[string]interface{}{"type": "ECDSA", "curve": "P-256", "bits": 256}, true},
+		{"key matches cert", "(.key | private_key_decode | .[0].publicKeyFingerprintSHA256) == (.crt | x509_decode | .[0].publicKeyFingerprintSHA256)", true, true},
+		{"pem_split", ".crt | pem_split", []interface{}{cert1, cert2}, true},
+		{"pem_join", ".certs | pem_join", cert1 + cert2, true},
+		{"pem_join non-array", ".crt | pem_join", nil, false},
+	} {
+		testQuery(t, c.name, input, c.query, c.expected, c.valid)
+	}
+}
@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/mgoltzsche/ktransform/pkg/pipeline"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-ktransform-mgoltzsche-github-com-v1alpha1-secrettransform,mutating=false,failurePolicy=fail,groups=ktransform.mgoltzsche.github.com,resources=secrettransforms,versions=v1alpha1,name=vsecrettransform.ktransform.mgoltzsche.github.com
+// +kubebuilder:webhook:verbs=create;update,path=/validate-ktransform-mgoltzsche-github-com-v1alpha1-clustersecrettransform,mutating=false,failurePolicy=fail,groups=ktransform.mgoltzsche.github.com,resources=clustersecrettransforms,versions=v1alpha1,name=vclustersecrettransform.ktransform.mgoltzsche.github.com
+
+const (
+	pathSecretTransform        = "/validate-ktransform-mgoltzsche-github-com-v1alpha1-secrettransform"
+	pathClusterSecretTransform = "/validate-ktransform-mgoltzsche-github-com-v1alpha1-clustersecrettransform"
+)
+
+// AddToManager registers the validating webhooks for SecretTransforms and ClusterSecretTransforms
+func AddToManager(mgr manager.Manager) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	server := mgr.GetWebhookServer()
+	for path, v := range validators(decoder) {
+		server.Register(path, &webhook.Admission{Handler: v})
+	}
+	return nil
+}
+
+// validators returns the spec validators mapped by their webhook path
+func validators(decoder *admission.Decoder) map[string]*specValidator {
+	return map[string]*specValidator{
+		pathSecretTransform: {
+			kind:     "SecretTransform",
+			new:      func() transformObject { return &ktransformv1alpha1.SecretTransform{} },
+			validate: pipeline.ValidateSpec,
+			decoder:  decoder,
+		},
+		pathClusterSecretTransform: {
+			kind:     "ClusterSecretTransform",
+			new:      func() transformObject { return &ktransformv1alpha1.ClusterSecretTransform{} },
+			validate: pipeline.ValidateClusterScopedSpec,
+			decoder:  decoder,
+		},
+	}
+}
+
+// transformObject is a SecretTransform or a ClusterSecretTransform
+type transformObject interface {
+	runtime.Object
+	metav1.Object
+	GetSpec() *ktransformv1alpha1.SecretTransformSpec
+}
+
+// specValidator rejects SecretTransforms or ClusterSecretTransforms with an invalid spec
+type specValidator struct {
+	kind     string
+	new      func() transformObject
+	validate func(spec *ktransformv1alpha1.SecretTransformSpec, path *field.Path) field.ErrorList
+	decoder  *admission.Decoder
+}
+
+var _ admission.Handler = &specValidator{}
+
+// Handle validates the spec of a created transform and spec changes of an updated transform.
+// Updates that don't change the spec (e.g. of finalizers) or of a transform that is being deleted
+// are allowed in order not to block the deletion of a transform that has been stored
+// before a validation rule has been introduced.
+func (v *specValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return admission.Allowed("")
+	}
+	obj := v.new()
+	if err := v.decoder.DecodeRaw(req.Object, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1beta1.Update {
+		if obj.GetDeletionTimestamp() != nil {
+			return admission.Allowed("")
+		}
+		old := v.new()
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(old.GetSpec(), obj.GetSpec()) {
+			return admission.Allowed("")
+		}
+	}
+	errs := v.validate(obj.GetSpec(), field.NewPath("spec"))
+	if len(errs) > 0 {
+		err := errors.NewInvalid(ktransformv1alpha1.SchemeGroupVersion.WithKind(v.kind).GroupKind(), obj.GetName(), errs)
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mgoltzsche/ktransform/pkg/apis"
+	ktransformv1alpha1 "github.com/mgoltzsche/ktransform/pkg/apis/ktransform/v1alpha1"
+	"github.com/stretchr/testify/require"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestSpecValidator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, apis.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	require.NoError(t, err)
+	name := "mysecret"
+	valid := ktransformv1alpha1.SecretTransformSpec{
+		Input: map[string]ktransformv1alpha1.InputRef{"in": {Secret: &name, Namespace: "infra"}},
+		Output: []ktransformv1alpha1.Output{{
+			Secret:         &ktransformv1alpha1.SecretOutput{Name: "a"},
+			Transformation: map[string]string{"k": ".in.k.string"},
+			Namespace:      "app",
+		}},
+	}
+	invalid := *valid.DeepCopy()
+	invalid.Output[0].Secret.Name = ""
+	for path, v := range validators(decoder) {
+		t.Run(path, func(t *testing.T) {
+			object := func(spec ktransformv1alpha1.SecretTransformSpec, modify func(transformObject)) runtime.RawExtension {
+				cr := v.new()
+				*cr.GetSpec() = spec
+				cr.GetObjectKind().SetGroupVersionKind(ktransformv1alpha1.SchemeGroupVersion.WithKind(v.kind))
+				cr.SetName("mytransform")
+				if modify != nil {
+					modify(cr)
+				}
+				b, err := json.Marshal(cr)
+				require.NoError(t, err)
+				return runtime.RawExtension{Raw: b}
+			}
+			request := func(op admissionv1beta1.Operation, old, obj runtime.RawExtension) admission.Request {
+				req := admission.Request{}
+				req.Operation = op
+				req.Object = obj
+				req.OldObject = old
+				return req
+			}
+			addFinalizer := func(o transformObject) { o.SetFinalizers([]string{"ktransform.mgoltzsche.github.com/finalizer"}) }
+			setDeletionTimestamp := func(o transformObject) {
+				now := metav1.Now()
+				o.SetDeletionTimestamp(&now)
+			}
+
+			res := v.Handle(context.TODO(), request(admissionv1beta1.Create, runtime.RawExtension{}, object(valid, nil)))
+			require.True(t, res.Allowed, "valid spec should be allowed: %v", res.Result)
+			res = v.Handle(context.TODO(), request(admissionv1beta1.Create, runtime.RawExtension{}, object(invalid, nil)))
+			require.False(t, res.Allowed, "invalid spec should be denied on create")
+			res = v.Handle(context.TODO(), request(admissionv1beta1.Update, object(valid, nil), object(invalid, nil)))
+			require.False(t, res.Allowed, "invalid spec change should be denied")
+			require.Contains(t, string(res.Result.Reason), "spec.output[0].secret.name")
+			res = v.Handle(context.TODO(), request(admissionv1beta1.Update, object(invalid, nil), object(invalid, addFinalizer)))
+			require.True(t, res.Allowed, "update with unchanged invalid spec should be allowed: %v", res.Result)
+			changed := *invalid.DeepCopy()
+			changed.Output[0].Transformation["k"] = ".in.other.string"
+			res = v.Handle(context.TODO(), request(admissionv1beta1.Update, object(invalid, nil), object(changed, setDeletionTimestamp)))
+			require.True(t, res.Allowed, "update of a deleted transform should be allowed: %v", res.Result)
+		})
+	}
+}
@@ -120,6 +120,56 @@ func testTransform(t *testing.T, ctx *framework.Context) {
 		waitForTransformation(t, cr, cr.Status.OutputHash, 40*time.Second)
 		assertOutput(t, prefix, ns, usr, pw, "changedCMValue", "registry0.example.org", "registry1.example.org")
 	})
+
+	t.Run("removed output should be pruned", func(t *testing.T) {
+		prefix := "outputpruning"
+		cr := createTestData(t, prefix, ns, usr, pw)
+		defer deleteTestCR(t, cr)
+		cr.Spec.Output = cr.Spec.Output[:1]
+		err := f.Client.Update(context.Background(), cr)
+		require.NoError(t, err)
+		waitForTransformation(t, cr, cr.Status.OutputHash, 10*time.Second)
+		require.Equal(t, 1, len(cr.Status.Outputs), "len(status.outputs)")
+		outKey := types.NamespacedName{Name: prefix + "-mergedconfigmap", Namespace: ns}
+		err = f.Client.Get(context.Background(), outKey, &corev1.ConfigMap{})
+		require.True(t, errors.IsNotFound(err), "expected removed output to be deleted but get returned %v", err)
+	})
+
+	t.Run("input selector should reconcile when Secrets start matching", func(t *testing.T) {
+		prefix := "inputselector"
+		labels := map[string]string{"app.kubernetes.io/part-of": prefix}
+		cr := &ktransformv1alpha1.SecretTransform{}
+		cr.Name = prefix + "-mytransform"
+		cr.Namespace = ns
+		cr.Spec.Input = map[string]ktransformv1alpha1.InputRef{
+			"secrets": ktransformv1alpha1.InputRef{SecretSelector: &metav1.LabelSelector{MatchLabels: labels}},
+		}
+		cr.Spec.Output = []ktransformv1alpha1.Output{
+			{
+				ConfigMap:      &ktransformv1alpha1.ConfigMapOutput{Name: prefix + "-names"},
+				Transformation: map[string]string{"names": `.secrets | keys | join(",")`},
+			},
+		}
+		err := f.Client.Create(context.Background(), cr, nil)
+		require.NoError(t, err, "create %T", cr)
+		defer deleteTestCR(t, cr)
+		waitForTransformation(t, cr, "", 10*time.Second)
+		for i := 0; i < 2; i++ {
+			sec := &corev1.Secret{}
+			sec.Name = fmt.Sprintf("%s-secret%d", prefix, i)
+			sec.Namespace = ns
+			sec.Labels = labels
+			err = f.Client.Create(context.Background(), sec, nil)
+			require.NoError(t, err, "create input secret")
+			defer f.Client.Delete(context.Background(), sec)
+		}
+		waitForTransformation(t, cr, cr.Status.OutputHash, 10*time.Second)
+		out := &corev1.ConfigMap{}
+		err = f.Client.Get(context.Background(), types.NamespacedName{Name: prefix + "-names", Namespace: ns}, out)
+		require.NoError(t, err, "get output configmap")
+		require.Equal(t, prefix+"-secret0,"+prefix+"-secret1", out.Data["names"], "output")
+		require.Equal(t, 2, len(cr.Status.ManagedReferences), "len(status.managedReferences)")
+	})
 }
 
 func deleteTestCR(t *testing.T, cr *ktransformv1alpha1.SecretTransform) {